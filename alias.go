@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// configDir is the directory where goversion stores its configuration, initialized in the main() function.
+var configDir string
+
+// alias prints the list of aliases or, if both the name and the version are specified, sets the alias.
+// Aliases can point to other aliases, they are resolved recursively by the use command.
+func alias(_ context.Context, args []string) error {
+	aliases, err := loadAliases()
+	if err != nil {
+		return err
+	}
+
+	switch len(args) {
+	case 0:
+		names := make([]string, 0, len(aliases))
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(output, "%s -> %s\n", name, aliases[name])
+		}
+		return nil
+	case 1:
+		return usageError{errors.New("no version has been specified")}
+	}
+
+	name, version := args[0], args[1]
+	if name == "main" || name == "latest" || name == "-" || versionRE.MatchString(name) {
+		return fmt.Errorf("unable to use %q as an alias name", name)
+	}
+
+	aliases[name] = version
+	if _, err := resolveAlias(aliases, name); err != nil {
+		return err
+	}
+
+	if err := saveAliases(aliases); err != nil {
+		return err
+	}
+
+	printf("Set %s -> %s\n", name, version)
+	return nil
+}
+
+// resolveAlias returns the version the given alias points to.
+// If the name is not an alias, it is returned as is.
+func resolveAlias(aliases map[string]string, name string) (string, error) {
+	seen := make(map[string]bool)
+	for {
+		target, ok := aliases[name]
+		if !ok {
+			return name, nil
+		}
+		if seen[name] {
+			return "", fmt.Errorf("alias %q is cyclic", name)
+		}
+		seen[name] = true
+		name = target
+	}
+}
+
+func aliasesPath() string { return filepath.Join(configDir, "aliases.json") }
+
+// loadAliases reads the aliases file, it's ok for the file to be missing.
+func loadAliases() (map[string]string, error) {
+	data, err := os.ReadFile(aliasesPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string]string)
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", aliasesPath(), err)
+	}
+
+	return aliases, nil
+}
+
+// saveAliases writes the aliases file, creating the config directory if needed.
+func saveAliases(aliases map[string]string) error {
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(aliasesPath(), append(data, '\n'), 0o644)
+}
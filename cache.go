@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheDir is the directory where goversion caches responses, initialized in the main() function.
+// If it's empty, caching is disabled.
+var cacheDir string
+
+// noCache disables reading the caches (they are still updated), set by the -no-cache flag.
+var noCache bool
+
+// defaultCacheTTL is used if $GOVERSION_CACHE_TTL is not set.
+const defaultCacheTTL = time.Hour
+
+// cachePath returns the path of the cache file for the given url,
+// so switching $GOVERSION_DL_URL does not return stale data.
+func cachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// cacheTTL returns the TTL from $GOVERSION_CACHE_TTL (e.g. 30m) or defaultCacheTTL if it's not set.
+func cacheTTL() (time.Duration, error) {
+	s := os.Getenv("GOVERSION_CACHE_TTL")
+	if s == "" {
+		return defaultCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid $GOVERSION_CACHE_TTL value %q", s)
+	}
+	return ttl, nil
+}
+
+// readCache returns the cached response for the given url or nil if there is no fresh one.
+func readCache(url string) ([]byte, error) {
+	if cacheDir == "" || noCache {
+		return nil, nil
+	}
+
+	ttl, err := cacheTTL()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(cachePath(url))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case time.Since(info.ModTime()) > ttl:
+		return nil, nil // the cache is stale.
+	}
+
+	return os.ReadFile(cachePath(url))
+}
+
+// readStaleCache returns the cached response for the given url, no matter how old it is,
+// along with the time it was cached. If there is no cached response, it returns nil.
+func readStaleCache(url string) ([]byte, time.Time, error) {
+	if cacheDir == "" {
+		return nil, time.Time{}, nil
+	}
+
+	info, err := os.Stat(cachePath(url))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, time.Time{}, nil
+	case err != nil:
+		return nil, time.Time{}, err
+	}
+
+	data, err := os.ReadFile(cachePath(url))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return data, info.ModTime(), nil
+}
+
+// writeCache caches the response for the given url.
+// Caching is best-effort, so errors are ignored.
+func writeCache(url string, data []byte) {
+	if cacheDir == "" {
+		return
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(cachePath(url), data, 0o644)
+}
+
+// keyedCache is a cached value along with the key it's valid for.
+type keyedCache struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// goBinaryKey returns the key for caching the output of the go binary found in $PATH of env:
+// its path, the path it resolves to (e.g. Homebrew links it into a versioned directory) and its mtime,
+// which change when Go is upgraded. goversion never modifies the binary, so its own writes don't invalidate the cache.
+// If any of them can't be determined, it returns an empty string, so the cache is not used.
+func goBinaryKey(env []string) string {
+	path, err := lookPath("go", getEnv(env, "PATH"))
+	if err != nil {
+		return ""
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return ""
+	}
+	bin, err := os.Stat(target)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %s %d", path, target, bin.ModTime().UnixNano())
+}
+
+// gobinCacheKey returns the key for caching the output of `go env GOBIN`:
+// the go binary (see goBinaryKey) and the mtime of the go env file, which is changed by `go env -w`.
+func gobinCacheKey() string {
+	key := goBinaryKey(os.Environ())
+	if key == "" {
+		return ""
+	}
+	file := os.Getenv("GOENV")
+	if file == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return ""
+		}
+		file = filepath.Join(dir, "go", "env")
+	}
+	var mtime int64 // the file may not exist (e.g. `go env -w` has never been used).
+	if info, err := os.Stat(file); err == nil {
+		mtime = info.ModTime().UnixNano()
+	}
+	return fmt.Sprintf("%s %s %d", key, file, mtime)
+}
+
+// readCachedValue returns the value cached under the name if it's valid for the given key.
+func readCachedValue(name, key string) (string, bool) {
+	if cacheDir == "" || noCache || key == "" {
+		return "", false
+	}
+	data, err := os.ReadFile(filepath.Join(cacheDir, name+".json"))
+	if err != nil {
+		return "", false
+	}
+	var cache keyedCache
+	if err := json.Unmarshal(data, &cache); err != nil || cache.Key != key {
+		return "", false
+	}
+	return cache.Value, true
+}
+
+// writeCachedValue caches the value under the name for the given key.
+// Like writeCache, it's best-effort, so errors are ignored.
+func writeCachedValue(name, key, value string) {
+	if cacheDir == "" || key == "" {
+		return
+	}
+	data, err := json.Marshal(keyedCache{Key: key, Value: value})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(filepath.Join(cacheDir, name+".json"), data, 0o644)
+}
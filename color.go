@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// noColor disables colors, set by the -no-color flag.
+var noColor bool
+
+// ANSI escape codes for the colors used by the list command.
+const (
+	colorReset  = "\033[0m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorDim    = "\033[2m"
+)
+
+// colorEnabled reports whether the text written to w should be colorized.
+// Colors are disabled by the -no-color flag, $NO_COLOR (https://no-color.org) and CLICOLOR=0,
+// otherwise they are only used if w is a terminal (unless $CLICOLOR_FORCE is set), so piped output stays clean.
+func colorEnabled(w io.Writer) bool {
+	switch {
+	case noColor, os.Getenv("NO_COLOR") != "", os.Getenv("CLICOLOR") == "0":
+		return false
+	case os.Getenv("CLICOLOR_FORCE") != "" && os.Getenv("CLICOLOR_FORCE") != "0":
+		return true
+	default:
+		return isTerminal(w)
+	}
+}
+
+// paint wraps s in the color escape codes if enabled is true.
+func paint(enabled bool, color, s string) string {
+	if !enabled || s == "" {
+		return s
+	}
+	return color + s + colorReset
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -11,24 +14,193 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 )
 
 // abstractions for $GOBIN and $HOME/sdk, initialized in the main() function.
 var gobin, sdk fsx
 
+// patch versions may be zero (e.g. 1.21.0) but only when the minor version is present.
+//
 //nolint:gocritic // regexpSimplify: [0-9] reads better here than \d
-var versionRE = regexp.MustCompile(`^(1(\.[1-9][0-9]*)?(\.[1-9][0-9]*)?((rc|beta)[1-9]+)?|tip)$`)
+var versionRE = regexp.MustCompile(`^(1(\.[1-9][0-9]*(\.(0|[1-9][0-9]*))?)?((rc|beta)[1-9][0-9]*)?|tip)$`)
 
 // use switches the current Go version to the one specified.
 // If it's not installed, use will install it and download its SDK first.
 func use(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("use", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	var ifChanged bool
+	fset.BoolVar(&ifChanged, "if-changed", false, "do nothing if the version is already in use")
+
+	var force bool
+	fset.BoolVar(&force, "force", false, "re-download the SDK even if it's already downloaded")
+
+	var noChecksum bool
+	fset.BoolVar(&noChecksum, "no-checksum", false, "do not verify the downloaded SDK against the checksum from go.dev")
+
+	var printPath bool
+	fset.BoolVar(&printPath, "print-path", false, "print the export PATH line instead of switching the symlink")
+
+	var dryRun bool
+	fset.BoolVar(&dryRun, "dry-run", false, "print what would be done without doing it")
+
+	var noSwitch bool
+	fset.BoolVar(&noSwitch, "no-switch", false, "install the version and download its SDK but do not switch to it")
+
+	var locked bool
+	fset.BoolVar(&locked, "locked", false, "switch to the exact version from the goversion.lock file")
+
+	var quietIfCurrent bool
+	fset.BoolVar(&quietIfCurrent, "quiet-if-current", false, "print nothing if the version is already in use")
+
+	var preferInstalled bool
+	fset.BoolVar(&preferInstalled, "prefer-installed", false, "resolve latest to the newest installed version without going online")
+
+	var channel string
+	fset.StringVar(&channel, "channel", "stable", "the release channel for latest: stable, rc or beta")
+
+	var printJSON bool
+	fset.BoolVar(&printJSON, "json", false, "print the progress as newline-delimited JSON events (to stdout)")
+
+	var goos, goarch string
+	fset.StringVar(&goos, "os", "", "download the SDK for this OS instead of switching (e.g. linux)")
+	fset.StringVar(&goarch, "arch", "", "download the SDK for this architecture instead of switching (e.g. arm64)")
+
+	if err := fset.Parse(args); err != nil {
+		return usageError{err}
+	}
+
+	// in the dry-run mode the output mirrors the real one, so it's easy to tell what would happen.
+	say := printf
+	if dryRun {
+		say = func(format string, args ...any) { printf("[dry-run] "+format, args...) }
+	}
+
+	if printPath && ifChanged {
+		return usageError{errors.New("-print-path and -if-changed can't be used together")}
+	}
+
+	switch channel {
+	case "stable", "rc", "beta":
+	default:
+		return usageError{fmt.Errorf("unknown channel %q", channel)}
+	}
+
+	cross := goos != "" || goarch != ""
+	if cross && (printPath || ifChanged) {
+		return usageError{errors.New("-os and -arch can't be used with -print-path or -if-changed")}
+	}
+
+	if printJSON && (printPath || dryRun || cross) {
+		return usageError{errors.New("-json can't be used with -print-path, -dry-run, -os or -arch")}
+	}
+
+	// emit prints the event for a frontend (e.g. a GUI wrapper) to show the progress.
+	// stdout is not buffered, so each event is delivered as soon as it's printed.
+	// An error means the frontend is gone (e.g. a closed pipe), so there is no point in going on.
+	emit := func(event, version string) error {
+		if !printJSON {
+			return nil
+		}
+		return json.NewEncoder(stdout).Encode(useEvent{Event: event, Version: version})
+	}
+	// the output of the post-use hook must not get mixed up with the events.
+	hookOutput := stdout
+	if printJSON {
+		// the events replace the human-readable messages (warnings and errors are still printed).
+		defer func(q bool) { quiet = q }(quiet)
+		quiet = true
+		hookOutput = output
+	}
+
+	args = fset.Args()
+	if locked {
+		if len(args) > 0 {
+			return usageError{errors.New("-locked can't be used with a version")}
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		version, err := versionFromLockfile(wd)
+		if err != nil {
+			return err
+		}
+		args = []string{version}
+	}
 	if len(args) == 0 {
-		return usageError{errors.New("no version has been specified")}
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		version, err := versionFromFile(wd)
+		if errors.Is(err, fs.ErrNotExist) {
+			// fall back to the default version from the config, if any.
+			cfg, cfgErr := loadConfig()
+			if cfgErr != nil {
+				return cfgErr
+			}
+			if cfg.Default != "" {
+				version, err = cfg.Default, nil
+			}
+		}
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && ifChanged:
+			return nil
+		case errors.Is(err, fs.ErrNotExist) && interactive():
+			local, err := localVersions(ctx)
+			if err != nil {
+				return err
+			}
+			if version, err = pickVersion(local, stdin, output); err != nil {
+				return err
+			}
+		case errors.Is(err, fs.ErrNotExist):
+			return usageError{errors.New("no version has been specified")}
+		case err != nil:
+			return err
+		}
+		args = []string{version}
+	}
+
+	aliases, err := loadAliases()
+	if err != nil {
+		return err
+	}
+
+	version, err := resolveAlias(aliases, trimGo(args[0]))
+	if err != nil {
+		return err
+	}
+	version = trimGo(version) // the alias may point to a go-prefixed version as well.
+
+	if channel != "stable" && version != "latest" {
+		return usageError{errors.New("-channel can only be used with latest")}
+	}
+	if preferInstalled && (version != "latest" || channel != "stable") {
+		return usageError{errors.New("-prefer-installed can only be used with latest (the stable channel)")}
+	}
+
+	// fast path: reading the symlink is enough to know the current version,
+	// so we don't have to spawn `go version` (useful for shell hooks).
+	if ifChanged {
+		if target, err := gobin.Readlink(linkName()); err == nil && strings.TrimPrefix(filepath.Base(target), "go") == version {
+			// unless the symlink is dangling, see localVersions.
+			if _, err := fs.Stat(gobin, exe(filepath.Base(target))); err == nil {
+				return nil
+			}
+		}
 	}
 
 	local, err := localVersions(ctx)
@@ -36,60 +208,344 @@ func use(ctx context.Context, args []string) error {
 		return err
 	}
 
-	version := args[0]
-	if version == "main" {
+	// the best-effort go.dev checks after the switch are skipped if the version has been resolved locally on purpose.
+	var offline bool
+
+	if local.dangling != "" {
+		say("The go symlink points to a missing binary (go%s), it will be replaced\n", local.dangling)
+	}
+
+	switch version {
+	case "main":
 		version = local.main
+	case "-":
+		if version, err = loadPrevious(); err != nil {
+			return err
+		}
+	case "latest":
+		if preferInstalled {
+			if version = local.newestStable(); version != "" {
+				logger.Printf("resolved latest to the newest installed version %s", version)
+				offline = true
+				break
+			}
+			logger.Printf("no stable versions installed, resolving latest from go.dev")
+		}
+		remote, err := remoteVersions(ctx, true)
+		if err != nil {
+			return err
+		}
+		if version = remote.latestIn(channel); version == "" {
+			return notFoundError{fmt.Errorf("no %s version found on go.dev", channel)}
+		}
+	default:
+		// the locked version must be matched exactly.
+		if !locked {
+			version = resolveMinor(ctx, local, version)
+		}
 	}
 
 	if !versionRE.MatchString(version) {
-		return fmt.Errorf("malformed version %q", version)
+		return malformedError{version}
 	}
 
-	switch version {
-	case local.current:
-		fmt.Fprintf(output, "%s is already in use\n", version)
+	// the SDK for another platform can't run locally, so there is nothing to switch to.
+	if cross {
+		if goos == "" {
+			goos = runtime.GOOS
+		}
+		if goarch == "" {
+			goarch = runtime.GOARCH
+		}
+		msg := fmt.Sprintf("Downloading %s SDK for %s/%s ...", version, goos, goarch)
+		if dryRun {
+			say("%s\n", msg)
+			return nil
+		}
+		var path string
+		err := withProgress(msg, func() (err error) {
+			path, err = downloadCrossSDK(ctx, version, goos, goarch)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		printf("Downloaded %s SDK for %s/%s to %s\n", version, goos, goarch, path)
 		return nil
-	case local.main:
+	}
+
+	if force && version == local.main {
+		return fmt.Errorf("unable to re-download %s (main)", version)
+	}
+
+	switch {
+	case version == local.main && printPath:
+		// the main version lives outside of $GOBIN, so we need to look for it in $PATH.
+		path, err := lookPath("go", getEnv(envWithoutGOBIN(os.Environ()), "PATH"))
+		if err != nil {
+			return err
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			return err
+		}
+		printExportPath(filepath.Dir(path))
+		return nil
+	case version == local.current && !force && !printPath:
+		if !ifChanged && !quietIfCurrent {
+			say("%s is already in use\n", version)
+		}
+		return emit("unchanged", version)
+	case version == local.main && noSwitch:
+		say("%s is ready (main)\n", version)
+		return emit("ready", version)
+	case version == local.main:
 		// for switching to the main version simply removing the symlink is enough.
-		if err := gobin.Remove("go"); err != nil {
+		if !dryRun {
+			logger.Printf("removing the go symlink to switch to %s (main)", version)
+			if err := gobin.Remove(linkName()); err != nil {
+				return err
+			}
+			if err := savePrevious(local.current); err != nil {
+				return err
+			}
+		}
+		say("Switched to %s (main)\n", version)
+		if err := emit("switched", version); err != nil {
 			return err
 		}
-		fmt.Fprintf(output, "Switched to %s (main)\n", version)
+		if !dryRun {
+			runPostUseHook(ctx, local.current, version, hookOutput)
+			if !offline {
+				warnOutdatedMain(ctx, version)
+			}
+		}
 		return nil
 	}
 
-	initial := false
+	// if the installation fails, the artifacts created by this invocation are removed, so a retry starts from scratch.
+	// The pre-existing ones (e.g. the binary of a version whose SDK is missing) are kept.
+	initial, downloading := false, false
+	rollback := func(err error) error {
+		if dryRun || !initial && !downloading {
+			return err
+		}
+		if downloading {
+			if err := removePartialSDK(version); err != nil {
+				logger.Printf("unable to remove the partial %s SDK: %v", version, err)
+			}
+		}
+		if initial {
+			if err := gobin.Remove("go" + version); err != nil {
+				logger.Printf("unable to remove the go%s binary: %v", version, err)
+			}
+		}
+		printf("Rolled back the incomplete %s installation\n", version)
+		return err
+	}
+
 	if !local.contains(version) {
+		if networkDisabled() {
+			return fmt.Errorf("%s is not installed: %w", version, errNoNetwork)
+		}
 		initial = true
-		fmt.Fprintf(output, "%s is not installed. Looking for it on go.dev ...\n", version)
-		url := fmt.Sprintf("golang.org/dl/go%s@latest", version)
-		if err := command(ctx, "go", "install", url); err != nil {
+		say("%s is not installed. Looking for it on go.dev ...\n", version)
+		if err := emit("installing", version); err != nil {
 			return err
 		}
+		if !dryRun {
+			if err := installBinary(ctx, version); err != nil {
+				return err
+			}
+		}
+	}
+
+	// with -force, the existing SDK is removed, so it will be downloaded from scratch.
+	// the checks go first to avoid removing the SDK that can't be downloaded again.
+	if force && !initial {
+		switch {
+		case networkDisabled():
+			return fmt.Errorf("unable to re-download %s SDK: %w", version, errNoNetwork)
+		case customSDKDir():
+			return fmt.Errorf("unable to re-download %s SDK: %w", version, errCustomSDKDir)
+		}
+		say("Removing %s SDK ...\n", version)
+		if !dryRun {
+			if err := sdk.RemoveAll("go" + version); err != nil {
+				return err
+			}
+		}
 	}
 
 	// it's possible that SDK download was canceled during initial installation,
 	// so we need to ensure its presence even if the go<version> binary exists.
-	if !downloaded(version) {
-		if !initial {
-			// this message doesn't make sense during initial installation.
-			fmt.Fprintf(output, "%s SDK is missing. Starting download ...\n", version)
+	// (in the dry-run mode the SDK is not actually removed with -force).
+	if !downloaded(version) || dryRun && force {
+		if networkDisabled() {
+			return fmt.Errorf("%s SDK is missing: %w", version, errNoNetwork)
+		}
+		if customSDKDir() {
+			return rollback(fmt.Errorf("%s SDK is missing: %w", version, errCustomSDKDir))
+		}
+		if !initial && !force {
+			// this message doesn't make sense during initial installation or re-downloading.
+			say("%s SDK is missing. Starting download ...\n", version)
+		}
+		if dryRun {
+			say("Downloading %s SDK ...\n", version)
+		} else {
+			// a partially downloaded SDK may be corrupted, so it's safer to start from scratch.
+			// (with -force it has already been removed).
+			if !force {
+				if err := removePartialSDK(version); err != nil {
+					return rollback(err)
+				}
+			}
+			downloading = true
+			if err := emit("downloading", version); err != nil {
+				return rollback(err)
+			}
+			start := time.Now()
+			err := withProgress("Downloading "+version+" SDK ...", func() error {
+				return downloadSDK(ctx, version)
+			})
+			if err != nil {
+				return rollback(err)
+			}
+			reportDownload(version, time.Since(start))
+
+			// gotip is built from source, so there is nothing to verify.
+			if !noChecksum && version != "tip" {
+				if err := verifyChecksum(ctx, version); err != nil {
+					return rollback(err)
+				}
+			}
 		}
-		if err := command(ctx, "go"+version, "download"); err != nil {
+	}
+
+	if printPath {
+		printExportPath(sdk.Path("go" + version + "/bin"))
+		return nil
+	}
+
+	if noSwitch {
+		say("%s is ready\n", version)
+		return emit("ready", version)
+	}
+
+	if dryRun {
+		say("Switched to %s\n", version)
+		return nil
+	}
+
+	logger.Printf("replacing the %[1]s symlink with go%[2]s -> %[1]s", linkName(), version)
+	if err := replaceLink("go" + version); err != nil {
+		return err
+	}
+
+	// re-downloading the current version (-force) is not a switch.
+	if version != local.current {
+		if err := savePrevious(local.current); err != nil {
 			return err
 		}
 	}
 
-	// it's ok for the symlink to be missing if the previous version was the main one.
-	if err := gobin.Remove("go"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+	printf("Switched to %s\n", version)
+	if err := emit("switched", version); err != nil {
 		return err
 	}
-	if err := gobin.Symlink("go"+version, "go"); err != nil {
+	if version != local.current {
+		runPostUseHook(ctx, local.current, version, hookOutput)
+	}
+	if !offline {
+		warnEOL(ctx, version)
+	}
+	return nil
+}
+
+// runPostUseHook runs the command from $GOVERSION_POST_USE (if any) in the shell after switching to the new version,
+// e.g. to reinstall the tools with the new toolchain.
+// The versions are passed in $GOVERSION_OLD (empty if the go symlink was dangling) and $GOVERSION_NEW.
+// The output of the hook goes to w (see use for why it's not always stdout).
+// The switch is already done at this point, so a failure of the hook is reported, but not returned.
+func runPostUseHook(ctx context.Context, previous, version string, w io.Writer) {
+	script := os.Getenv("GOVERSION_POST_USE")
+	if script == "" {
+		return
+	}
+	env := setEnv(setEnv(os.Environ(), "GOVERSION_OLD", previous), "GOVERSION_NEW", version)
+	shell := shellCommand(script)
+	if err := command(withLiveOutput(ctx, w), env, shell[0], shell[1:]...); err != nil {
+		fmt.Fprintf(output, "Warning: the post-use hook failed: %v\n", err)
+	}
+}
+
+// shellCommand returns the command line to run the script in the system shell.
+func shellCommand(script string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", script}
+	}
+	return []string{"sh", "-c", script}
+}
+
+// useEvent is a progress event printed by the use command with the -json flag.
+type useEvent struct {
+	Event   string `json:"event"` // installing, downloading, switched, ready or unchanged.
+	Version string `json:"version,omitempty"`
+}
+
+// replaceLink points the go symlink to the target binary atomically: the new symlink is created under a temporary name
+// and renamed over the old one, so there is no moment without go in $PATH (e.g. for a concurrently running build).
+// It's ok for the symlink to be missing if the previous version was the main one.
+func replaceLink(target string) error {
+	tmp := "." + linkName() + ".tmp" // hidden, so it's never listed as a version.
+	err := gobin.Symlink(target, tmp)
+	if errors.Is(err, fs.ErrExist) {
+		// left after goversion has been killed in the middle of the switch.
+		if err := gobin.Remove(tmp); err != nil {
+			return err
+		}
+		err = gobin.Symlink(target, tmp)
+	}
+	if err != nil {
 		return err
 	}
+	return gobin.Rename(tmp, linkName())
+}
+
+// warnEOL prints a warning if the minor release of the version is no longer supported,
+// i.e. it's older than the two latest stable minor releases on go.dev.
+// The check is best-effort: it's skipped in the offline mode and if go.dev is not reachable (see quickRemoteVersions).
+func warnEOL(ctx context.Context, version string) {
+	if version == "tip" || networkDisabled() {
+		return
+	}
+	remote, err := quickRemoteVersions(ctx)
+	if err != nil {
+		logger.Printf("unable to check if %s is end-of-life: %v", version, err)
+		return
+	}
+	if supported := remote.supportedMinors(); len(supported) == 2 && versionLess(supported[1], minorRelease(version)) {
+		fmt.Fprintf(output, "Warning: %s is end-of-life and no longer supported (the supported releases are %s and %s)\n",
+			minorRelease(version), supported[0], supported[1])
+	}
+}
 
-	fmt.Fprintf(output, "Switched to %s\n", version)
-	return nil
+// warnOutdatedMain prints a warning if the main version is behind the latest stable version on go.dev.
+// The main toolchain is managed outside of goversion (e.g. by a package manager), so it's not updated automatically.
+// Like warnEOL, the check is best-effort.
+func warnOutdatedMain(ctx context.Context, main string) {
+	if networkDisabled() {
+		return
+	}
+	remote, err := quickRemoteVersions(ctx)
+	if err != nil {
+		logger.Printf("unable to check if %s (main) is outdated: %v", main, err)
+		return
+	}
+	if latest := remote.latest(); latest != "" && versionLess(latest, main) {
+		fmt.Fprintf(output, "Warning: %s (main) is behind the latest stable version %s, "+
+			"update it from https://go.dev/dl or with the package manager it was installed with\n", main, latest)
+	}
 }
 
 // list prints the list of installed Go versions, highlighting the current one.
@@ -102,56 +558,1229 @@ func list(ctx context.Context, args []string) error {
 	fset.BoolVar(&printAll, "a", false, "shorthand for -all")
 	fset.BoolVar(&printAll, "all", false, "print available versions from go.dev as well")
 
+	var installedOnly bool
+	fset.BoolVar(&installedOnly, "installed-only", false, "print only installed versions (default)")
+
+	var remoteOnly bool
+	fset.BoolVar(&remoteOnly, "remote-only", false, "print only available versions from go.dev, without local annotations")
+
 	var only string
-	fset.StringVar(&only, "only", "", "print only versions starting with this prefix")
+	fset.StringVar(&only, "only", "", "print only versions starting with this prefix (or matching a comparison, e.g. >=1.20)")
+
+	var printJSON bool
+	fset.BoolVar(&printJSON, "json", false, "print versions in JSON format (to stdout)")
+
+	var printSize bool
+	fset.BoolVar(&printSize, "size", false, "print the disk space used by each SDK")
+
+	// the same as the global -no-cache flag, which is used as the default.
+	fset.BoolVar(&noCache, "no-cache", noCache, "do not use the cached data")
+
+	var outdated bool
+	fset.BoolVar(&outdated, "outdated", false, "print only installed versions that have newer patches")
+
+	var offlineFallback bool
+	fset.BoolVar(&offlineFallback, "offline-fallback", false, "use the cached list of available versions if go.dev is not reachable")
+
+	var group bool
+	fset.BoolVar(&group, "group", false, "group versions by minor release")
+
+	var format string
+	fset.StringVar(&format, "format", "", "print each version using the Go template (to stdout)")
+
+	var stableOnly bool
+	fset.BoolVar(&stableOnly, "stable", false, "print only stable versions from go.dev")
+
+	var prereleaseOnly bool
+	fset.BoolVar(&prereleaseOnly, "prerelease", false, "print only rc/beta versions")
+
+	var order string
+	fset.StringVar(&order, "sort", "desc", "sort versions newest-first (desc) or oldest-first (asc)")
+
+	var porcelain bool
+	fset.BoolVar(&porcelain, "porcelain", false, "print versions in a stable format for scripts (to stdout)")
+
+	var allPatches bool
+	fset.BoolVar(&allPatches, "all-patches", false, "print all patches of the minor release from -only (from go.dev as well)")
+
+	var latestOnly bool
+	fset.BoolVar(&latestOnly, "latest-only", false, "print only the newest patch of each minor release")
+
+	var count bool
+	fset.BoolVar(&count, "count", false, "print only the number of versions (to stdout)")
+
+	var noMain bool
+	fset.BoolVar(&noMain, "no-main", false, "exclude the main version")
+
+	if err := fset.Parse(args); err != nil {
+		return usageError{err}
+	}
+
+	switch {
+	case installedOnly && (printAll || remoteOnly):
+		return usageError{errors.New("-installed-only can't be used with -all or -remote-only")}
+	case remoteOnly && (printAll || outdated):
+		return usageError{errors.New("-remote-only can't be used with -all or -outdated")}
+	case format != "" && (printJSON || group):
+		return usageError{errors.New("-format can't be used with -json or -group")}
+	case porcelain && (printJSON || group || format != ""):
+		return usageError{errors.New("-porcelain can't be used with -json, -group or -format")}
+	case count && (printJSON || group || format != "" || porcelain || printSize):
+		return usageError{errors.New("-count can't be used with -json, -group, -format, -porcelain or -size")}
+	case allPatches && !minorRE.MatchString(only):
+		return usageError{errors.New("-all-patches requires a minor release in -only (e.g. -only=1.20)")}
+	case allPatches && (installedOnly || remoteOnly):
+		return usageError{errors.New("-all-patches can't be used with -installed-only or -remote-only")}
+	case allPatches && latestOnly:
+		return usageError{errors.New("-all-patches can't be used with -latest-only")}
+	case stableOnly && !printAll && !remoteOnly:
+		return usageError{errors.New("-stable can only be used with -all or -remote-only")}
+	case prereleaseOnly && !printAll && !remoteOnly:
+		return usageError{errors.New("-prerelease can only be used with -all or -remote-only")}
+	case prereleaseOnly && stableOnly:
+		return usageError{errors.New("-prerelease can't be used with -stable")}
+	case order != "asc" && order != "desc":
+		return usageError{fmt.Errorf("unknown sort order %q", order)}
+	}
+
+	var tmpl *template.Template
+	if format != "" {
+		var err error
+		if tmpl, err = template.New("format").Parse(format); err != nil {
+			return usageError{err}
+		}
+	}
+
+	match, err := versionFilter(only)
+	if err != nil {
+		return usageError{err}
+	}
+
+	// unlike the prefix, the minor release doesn't match e.g. 1.2 for 1.20 or rc/beta versions.
+	if allPatches {
+		printAll = true
+		match = func(v string) bool {
+			return minorRelease(v) == only && !strings.ContainsAny(v, "br")
+		}
+	}
+
+	// with -remote-only the local versions are not needed, so there is no need to run `go version`.
+	local := new(local)
+	if !remoteOnly {
+		if local, err = localVersions(ctx); err != nil {
+			return err
+		}
+	}
+
+	// the SDKs of the versions removed with `rm -keep-sdk` are still there.
+	var sdkOnly []string
+	if !remoteOnly {
+		if sdkOnly, err = sdkOnlyVersions(local); err != nil {
+			return err
+		}
+	}
+
+	versions := local.list
+	if len(sdkOnly) > 0 {
+		versions = append(append([]string(nil), local.list...), sdkOnly...)
+		sort.Slice(versions, func(i, j int) bool {
+			return versionLess(versions[i], versions[j])
+		})
+	}
+
+	remote := new(remote)
+	if printAll || remoteOnly || outdated {
+		remote, err = remoteVersions(ctx, true)
+		if err != nil && offlineFallback && errors.As(err, new(networkError)) {
+			cached, cachedAt, cacheErr := cachedRemoteVersions()
+			if cacheErr != nil {
+				return fmt.Errorf("%w (offline fallback: %v)", err, cacheErr)
+			}
+			fmt.Fprintf(output, "Warning: %v, using the list cached at %s\n", err, cachedAt.Format("2006-01-02 15:04:05"))
+			remote, err = cached, nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case remoteOnly:
+		versions = remote.list
+	case printAll && !outdated:
+		// the local versions that are not published on go.dev (e.g. removed from there) are kept,
+		// so the list covers both the local and the remote state.
+		merged := append([]string(nil), remote.list...)
+		for _, v := range versions {
+			if !contains(remote.list, v) {
+				merged = append(merged, v)
+			}
+		}
+		if len(merged) > len(remote.list) {
+			sort.Slice(merged, func(i, j int) bool {
+				return versionLess(merged[i], merged[j])
+			})
+		}
+		versions = merged
+	}
+
+	// sort the list regardless of its source, so the order is the same for local and remote versions.
+	versions = append([]string(nil), versions...)
+	sort.SliceStable(versions, func(i, j int) bool {
+		if order == "asc" {
+			return versionLess(versions[j], versions[i])
+		}
+		return versionLess(versions[i], versions[j])
+	})
+
+	entries := []listEntry{} // not nil, so an empty list is encoded as [].
+	for _, version := range versions {
+		if !match(version) || noMain && version == local.main {
+			continue
+		}
+		if stableOnly && !contains(remote.stable, version) {
+			continue
+		}
+		if prereleaseOnly && !strings.Contains(version, "rc") && !strings.Contains(version, "beta") {
+			continue
+		}
+
+		var update string
+		if outdated {
+			latest := remote.latestPatch(version)
+			if latest == "" || !versionLess(latest, version) {
+				continue // already the latest patch.
+			}
+			update = latest
+		}
+
+		installed := local.contains(version)
+		rel, _ := remote.release(version)
+		entries = append(entries, listEntry{
+			Version:   version,
+			Current:   version == local.current,
+			Main:      version == local.main,
+			Installed: installed,
+			// the main version's SDK lives outside of the sdk directory.
+			SDK:    version == local.main || installed && downloaded(version) || contains(sdkOnly, version),
+			Update: update,
+			Kind:   rel.Kind,
+			Files:  rel.Files,
+		})
+	}
+
+	if latestOnly {
+		entries = newestPatches(entries)
+	}
+
+	if count {
+		n := 0
+		for _, e := range entries {
+			switch {
+			case remoteOnly:
+				n++
+			case printAll:
+				// with -all, only the versions available on go.dev are counted, not the local-only ones.
+				if contains(remote.list, e.Version) {
+					n++
+				}
+			default:
+				// the versions with a missing binary are listed, but they are not installed.
+				if e.Installed {
+					n++
+				}
+			}
+		}
+		fmt.Fprintln(stdout, n)
+		return nil
+	}
+
+	if printSize {
+		for i := range entries {
+			if entries[i].Main || !entries[i].SDK {
+				continue
+			}
+			if entries[i].Size, err = sdkSize(entries[i].Version); err != nil {
+				return err
+			}
+		}
+	}
+
+	if printJSON {
+		return json.NewEncoder(stdout).Encode(entries)
+	}
+
+	if porcelain {
+		for _, e := range entries {
+			fmt.Fprintf(stdout, "%s\t%s\n", e.Version, porcelainFlags(e))
+		}
+		return nil
+	}
+
+	if tmpl != nil {
+		for _, e := range entries {
+			if err := tmpl.Execute(stdout, e); err != nil {
+				return err
+			}
+			fmt.Fprintln(stdout)
+		}
+		return nil
+	}
+
+	color := colorEnabled(output)
+
+	var release string
+	for _, e := range entries {
+		// the entries are sorted, so the versions of the same minor release go one after another.
+		indent := ""
+		if group {
+			if r := minorRelease(e.Version); r != release {
+				release = r
+				fmt.Fprintf(output, "%s\n", release)
+			}
+			indent = "  "
+		}
+
+		var extra string
+		switch {
+		case remoteOnly:
+		case e.Main:
+			extra = " " + paint(color, colorDim, "(main)")
+		case !e.SDK && e.Installed:
+			extra = " " + paint(color, colorYellow, "(missing SDK)")
+		case e.SDK && !e.Installed:
+			extra = " " + paint(color, colorYellow, "(binary missing)")
+		case printAll && e.Installed:
+			// all versions are installed by default, so it's only worth noting alongside remote ones.
+			extra = " " + paint(color, colorDim, "(installed)")
+		}
+		if e.Update != "" {
+			extra = " -> " + e.Update + " available" + extra
+		}
+
+		// the version is padded before painting, since the escape codes would count towards the width.
+		prefix, version := " ", fmt.Sprintf("%-10s", e.Version)
+		switch {
+		case e.Current:
+			prefix = paint(color, colorGreen, "*")
+			version = paint(color, colorGreen, version)
+		case printAll && !e.Installed && !e.SDK:
+			version = paint(color, colorDim, version) // available on go.dev only.
+		}
+
+		if printSize {
+			size := "-" // the main version's SDK lives outside of the sdk directory.
+			if !e.Main && e.SDK {
+				size = formatSize(e.Size)
+			}
+			fmt.Fprintf(output, "%s%s %s %10s%s\n", indent, prefix, version, size, extra)
+			continue
+		}
+
+		fmt.Fprintf(output, "%s%s %s%s\n", indent, prefix, version, extra)
+	}
+
+	return nil
+}
+
+// listEntry is a single version printed by the list command, it's also passed to the -format template.
+type listEntry struct {
+	Version   string `json:"version"`
+	Current   bool   `json:"current"`
+	Main      bool   `json:"main"`
+	Installed bool   `json:"installed"`
+	SDK       bool   `json:"sdk"`
+	Size      int64  `json:"size,omitempty"`   // only set if the -size flag is provided.
+	Update    string `json:"update,omitempty"` // only set if the -outdated flag is provided.
+
+	// only set if the version is published on go.dev and the list is fetched (-all, -remote-only or -outdated).
+	Kind  string        `json:"kind,omitempty"`
+	Files []releaseFile `json:"files,omitempty"`
+}
+
+// newestPatches keeps only the newest entry of each minor release (e.g. 1.20.7 out of 1.20.1 and 1.20.7),
+// preserving the order of the entries.
+func newestPatches(entries []listEntry) []listEntry {
+	newest := make(map[string]string)
+	for _, e := range entries {
+		r := minorRelease(e.Version)
+		if v, ok := newest[r]; !ok || versionLess(e.Version, v) {
+			newest[r] = e.Version
+		}
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if newest[minorRelease(e.Version)] == e.Version {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// porcelainFlags returns the status flags of the entry for the -porcelain output:
+// a comma-separated list of current, main, installed and sdk (in this order) or - if none apply.
+// The format is guaranteed to be stable, new flags may only be appended.
+func porcelainFlags(e listEntry) string {
+	var flags []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"current", e.Current},
+		{"main", e.Main},
+		{"installed", e.Installed},
+		{"sdk", e.SDK},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	if len(flags) == 0 {
+		return "-"
+	}
+	return strings.Join(flags, ",")
+}
+
+// sdkOnlyVersions returns the versions whose SDKs are downloaded but binaries are missing from $GOBIN
+// (e.g. removed with `rm -keep-sdk`).
+func sdkOnlyVersions(local *local) ([]string, error) {
+	entries, err := fs.ReadDir(sdk, ".")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []string
+	for _, entry := range entries {
+		version := strings.TrimPrefix(entry.Name(), "go")
+		if !versionRE.MatchString(version) || local.contains(version) {
+			continue
+		}
+		if downloaded(version) {
+			list = append(list, version)
+		}
+	}
+
+	return list, nil
+}
+
+// sdkSize returns the disk space used by the SDK of the specified Go version.
+func sdkSize(version string) (int64, error) {
+	var size int64
+	err := fs.WalkDir(sdk, "go"+version, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// formatSize formats the given size in bytes in a human-readable form, e.g. 1.2 GiB.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// remove removes the specified Go version (both the binary and the SDK).
+// If this version is current, remove will switch to the main one first.
+// The version can be a glob pattern (see path.Match), e.g. 1.19.*, to remove all matching installed versions.
+// If the -only flag is provided, remove removes all installed versions starting with this prefix
+// (or matching a comparison, e.g. <1.19).
+// If the -all-except flag is provided, remove removes all installed versions except the specified ones (and main).
+// If the -keep-sdk flag is provided, remove removes only the binary, keeping the SDK for later.
+// If the -fallback=latest flag is provided, remove switches to the newest installed version instead of the main one
+// (or to the main one, if there are no other versions).
+func remove(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("remove", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	var only string
+	fset.StringVar(&only, "only", "", "remove all installed versions starting with this prefix (or matching a comparison, e.g. <1.19)")
+
+	var keepSDK bool
+	fset.BoolVar(&keepSDK, "keep-sdk", false, "remove only the binary, keeping the SDK")
+
+	var allExcept bool
+	fset.BoolVar(&allExcept, "all-except", false, "remove all installed versions except the specified ones (and main)")
+
+	var fallback string
+	fset.StringVar(&fallback, "fallback", "main", "the version to switch to if the current one is removed: main or latest")
+
+	if err := fset.Parse(args); err != nil {
+		return usageError{err}
+	}
+
+	if fallback != "main" && fallback != "latest" {
+		return usageError{fmt.Errorf("unknown fallback %q", fallback)}
+	}
+
+	args = fset.Args()
+	switch {
+	case len(args) == 0 && only == "":
+		return usageError{errors.New("no version has been specified")}
+	case len(args) > 0 && only != "":
+		return usageError{errors.New("-only and a version can't be specified together")}
+	}
+
+	local, err := localVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var versions []string
+	if only != "" {
+		match, err := versionFilter(only)
+		if err != nil {
+			return usageError{err}
+		}
+		for _, version := range local.list {
+			// the main version is never removed, even if it matches the prefix.
+			if version != local.main && match(version) {
+				versions = append(versions, version)
+			}
+		}
+		if len(versions) == 0 {
+			return notFoundError{fmt.Errorf("no installed versions matching %q", only)}
+		}
+	} else if allExcept {
+		keep := make([]string, len(args))
+		for i, version := range args {
+			if keep[i] = trimGo(version); !versionRE.MatchString(keep[i]) {
+				return malformedError{keep[i]}
+			}
+		}
+		for _, version := range local.list {
+			// the main version is never removed, even if it's not in the keep list.
+			if version != local.main && !contains(keep, version) {
+				versions = append(versions, version)
+			}
+		}
+	} else if pattern := trimGo(args[0]); containsAny(pattern, "*?[") {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return usageError{fmt.Errorf("malformed pattern %q", pattern)}
+		}
+		for _, version := range local.list {
+			// the main version is never removed, even if it matches the pattern.
+			if matched, _ := path.Match(pattern, version); matched && version != local.main {
+				versions = append(versions, version)
+			}
+		}
+		if len(versions) == 0 {
+			return notFoundError{fmt.Errorf("no installed versions matching %q", pattern)}
+		}
+	} else {
+		version := trimGo(args[0])
+		if version == "main" {
+			version = local.main
+		}
+
+		if !versionRE.MatchString(version) {
+			return malformedError{version}
+		}
+
+		if !local.contains(version) {
+			return notFoundError{fmt.Errorf("%s is not installed", version)}
+		}
+
+		if version == local.main {
+			return fmt.Errorf("unable to remove %s (main)", version)
+		}
+
+		versions = []string{version}
+	}
+
+	var reclaimed int64
+	for _, version := range versions {
+		if version == local.current {
+			// switch to the fallback version first.
+			newest := ""
+			if fallback == "latest" {
+				newest = local.newestExcept(versions)
+			}
+			if newest == "" {
+				if err := gobin.Remove(linkName()); err != nil {
+					return err
+				}
+				printf("Switched to %s (main)\n", local.main)
+			} else {
+				if err := replaceLink("go" + newest); err != nil {
+					return err
+				}
+				printf("Switched to %s\n", newest)
+			}
+		}
+
+		if err := gobin.Remove("go" + version); err != nil {
+			return err
+		}
+		if keepSDK {
+			printf("Removed %s (the SDK is kept)\n", version)
+			continue
+		}
+		if allExcept && downloaded(version) {
+			size, err := sdkSize(version)
+			if err != nil {
+				return err
+			}
+			reclaimed += size
+		}
+		if err := sdk.RemoveAll("go" + version); err != nil {
+			return err
+		}
+
+		printf("Removed %s\n", version)
+	}
+
+	if allExcept {
+		printf("Removed %d version(s), reclaimed %s\n", len(versions), formatSize(reclaimed))
+	}
+
+	return nil
+}
+
+// gc removes orphaned SDKs, i.e. the SDK directories with no matching go<version> binary in $GOBIN
+// (e.g. left after removing the binary manually or a canceled installation).
+// If the -dry-run flag is provided, gc only prints what it would remove, prefixed with [dry-run].
+func gc(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("gc", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	var dryRun bool
+	fset.BoolVar(&dryRun, "dry-run", false, "print what would be removed without removing it")
+
+	if err := fset.Parse(args); err != nil {
+		return usageError{err}
+	}
+
+	say := printf
+	if dryRun {
+		say = func(format string, args ...any) { printf("[dry-run] "+format, args...) }
+	}
+
+	local, err := localVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries, err := fs.ReadDir(sdk, ".")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var removed int
+	var reclaimed int64
+	for _, entry := range entries {
+		version := strings.TrimPrefix(entry.Name(), "go")
+		// unlike sdkOnlyVersions, partially downloaded SDKs are orphans as well.
+		if !versionRE.MatchString(version) || local.contains(version) {
+			continue
+		}
+
+		size, err := sdkSize(version)
+		if err != nil {
+			return err
+		}
+		if !dryRun {
+			if err := sdk.RemoveAll("go" + version); err != nil {
+				return err
+			}
+		}
+
+		say("Removed %s SDK (%s)\n", version, formatSize(size))
+		removed++
+		reclaimed += size
+	}
+
+	if removed == 0 {
+		say("Nothing to clean up\n")
+		return nil
+	}
+
+	say("Removed %d orphaned SDK(s), reclaimed %s\n", removed, formatSize(reclaimed))
+	return nil
+}
+
+// trimGo trims the go prefix from the version copied from the `go version` output, e.g. go1.21 -> 1.21.
+// Anything that doesn't become a valid version (e.g. an alias) is returned as is.
+func trimGo(version string) string {
+	if v := strings.TrimPrefix(version, "go"); versionRE.MatchString(v) {
+		return v
+	}
+	return version
+}
+
+// printExportPath prints the line that prepends the directory to $PATH when evaluated by a POSIX shell.
+func printExportPath(dir string) {
+	fmt.Fprintf(stdout, "export PATH=%s%c$PATH\n", dir, os.PathListSeparator)
+}
+
+// versionFromFile reads the version from the go.mod file (the toolchain directive, then the go one)
+// or the .go-version file, starting from the given directory and walking up to the root.
+func versionFromFile(dir string) (string, error) {
+	for {
+		version, err := versionFromGoMod(filepath.Join(dir, "go.mod"))
+		switch {
+		case err == nil && version != "":
+			return version, nil
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return "", err
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, ".go-version"))
+		switch {
+		case err == nil:
+			version, _, _ := strings.Cut(string(data), "\n")
+			return strings.TrimSpace(version), nil
+		case !errors.Is(err, fs.ErrNotExist):
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fs.ErrNotExist // we've reached the root.
+		}
+		dir = parent
+	}
+}
+
+// versionFromGoMod reads the version from the toolchain directive of the go.mod file,
+// falling back to the go directive. If there are none, an empty string is returned.
+func versionFromGoMod(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	var goVersion, toolchain string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		switch fields := strings.Fields(line); {
+		case len(fields) != 2:
+		case fields[0] == "go":
+			goVersion = fields[1]
+		case fields[0] == "toolchain" && fields[1] != "default":
+			toolchain = trimGo(fields[1])
+		}
+	}
+
+	version := goVersion
+	if toolchain != "" {
+		version = toolchain
+	}
+	if version != "" && !versionRE.MatchString(version) {
+		return "", fmt.Errorf("%s: %w", name, malformedError{version})
+	}
+	return version, nil
+}
+
+// maxConcurrency caps the default number of concurrent operations, so a slow proxy is not overwhelmed.
+const maxConcurrency = 4
+
+// concurrency returns the maximum number of concurrent operations (e.g. versions installed by the install command)
+// from $GOVERSION_CONCURRENCY or, if it's not set, the number of CPUs capped at maxConcurrency.
+func concurrency() (int, error) {
+	s := os.Getenv("GOVERSION_CONCURRENCY")
+	if s == "" {
+		if n := runtime.NumCPU(); n < maxConcurrency {
+			return n, nil
+		}
+		return maxConcurrency, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid $GOVERSION_CONCURRENCY value %q", s)
+	}
+	return n, nil
+}
+
+// install installs the specified Go versions (both the binaries and the SDKs) concurrently, without switching.
+// The number of concurrent installations is limited, see concurrency.
+// A failed installation does not abort the others, the results are reported at the end.
+// Versions that are already installed (including the main one) are skipped.
+func install(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("install", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	var noChecksum bool
+	fset.BoolVar(&noChecksum, "no-checksum", false, "do not verify the downloaded SDKs against the checksums from go.dev")
+
+	if err := fset.Parse(args); err != nil {
+		return usageError{err}
+	}
+
+	args = fset.Args()
+	if len(args) == 0 {
+		return usageError{errors.New("no version has been specified")}
+	}
+
+	for i, version := range args {
+		args[i] = trimGo(version)
+		if !versionRE.MatchString(args[i]) {
+			return malformedError{args[i]}
+		}
+	}
+
+	workers, err := concurrency()
+	if err != nil {
+		return err
+	}
+
+	local, err := localVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the same way as use does it.
+	for i, version := range args {
+		args[i] = resolveMinor(ctx, local, version)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	errs := make([]error, len(args))
+	skipped := make([]bool, len(args))
+
+	for i, version := range args {
+		if version == local.main || local.contains(version) && downloaded(version) {
+			skipped[i] = true
+			continue
+		}
+		wg.Add(1)
+		go func(i int, version string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = installVersion(ctx, version, local.contains(version), noChecksum)
+		}(i, version)
+	}
+	wg.Wait()
+
+	var failed int
+	for i, version := range args {
+		if errs[i] != nil {
+			failed++
+			fmt.Fprintf(output, "Failed to install %s: %v\n", version, errs[i])
+			continue
+		}
+		if skipped[i] {
+			printf("%s is already installed\n", version)
+			continue
+		}
+		printf("Installed %s\n", version)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d versions failed to install", failed, len(args))
+	}
+
+	return nil
+}
+
+// installBinary installs the go<version> binary from golang.org/dl.
+// If the version doesn't exist, a notFoundError is returned instead of the failure of go install.
+func installBinary(ctx context.Context, version string) error {
+	url := fmt.Sprintf("golang.org/dl/go%s@latest", version)
+	err := command(ctx, goInstallEnv(), "go", "install", url)
+	if err == nil {
+		return nil
+	}
+	// go install fails the same way for any reason, so go.dev tells whether the version exists at all.
+	if remote, rerr := remoteVersions(ctx, true); rerr == nil && !contains(remote.list, version) {
+		return notFoundError{fmt.Errorf("%s is not found on go.dev", version)}
+	}
+	return err
+}
+
+// installVersion installs the specified Go version and downloads its SDK,
+// skipping the steps that have already been done.
+// Like in use, the downloaded SDK is verified against the checksum from go.dev, unless noChecksum is true.
+func installVersion(ctx context.Context, version string, installed, noChecksum bool) error {
+	if !installed {
+		if networkDisabled() {
+			return errNoNetwork
+		}
+		if err := installBinary(ctx, version); err != nil {
+			return err
+		}
+	}
+	if !downloaded(version) {
+		if networkDisabled() {
+			return errNoNetwork
+		}
+		if customSDKDir() {
+			return errCustomSDKDir
+		}
+		if err := removePartialSDK(version); err != nil {
+			return err
+		}
+		start := time.Now()
+		if err := downloadSDK(ctx, version); err != nil {
+			return err
+		}
+		reportDownload(version, time.Since(start))
+
+		// gotip is built from source, so there is nothing to verify.
+		if !noChecksum && version != "tip" {
+			if err := verifyChecksum(ctx, version); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// upgrade installs the latest stable patch of the specified minor release (e.g. 1.20.7 for 1.20)
+// and switches to it if the current version belongs to the same minor release.
+// If the -prune flag is provided, upgrade removes the older patches of the minor release as well.
+func upgrade(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	var prune bool
+	fset.BoolVar(&prune, "prune", false, "remove the older patches of the minor release")
+
+	var noChecksum bool
+	fset.BoolVar(&noChecksum, "no-checksum", false, "do not verify the downloaded SDK against the checksum from go.dev")
+
+	if err := fset.Parse(args); err != nil {
+		return usageError{err}
+	}
+
+	args = fset.Args()
+	if len(args) == 0 {
+		return usageError{errors.New("no version has been specified")}
+	}
+
+	release := trimGo(args[0])
+	if !versionRE.MatchString(release) || release == "tip" {
+		return malformedError{release}
+	}
+	release = minorRelease(release)
+
+	local, err := localVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	remote, err := remoteVersions(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	latest := remote.latestPatch(release)
+	if latest == "" {
+		return notFoundError{fmt.Errorf("no stable %s release found on go.dev", release)}
+	}
+
+	switch {
+	case local.current == latest || minorRelease(local.current) != release && local.contains(latest) && downloaded(latest):
+		printf("%s is already the latest patch of %s\n", latest, release)
+	case minorRelease(local.current) == release:
+		useArgs := []string{latest}
+		if noChecksum {
+			useArgs = []string{"-no-checksum", latest}
+		}
+		if err := use(ctx, useArgs); err != nil {
+			return err
+		}
+	default:
+		if err := installVersion(ctx, latest, local.contains(latest), noChecksum); err != nil {
+			return err
+		}
+		printf("Installed %s\n", latest)
+	}
+
+	if !prune {
+		return nil
+	}
+
+	for _, version := range local.list {
+		// the main version is never removed, even if it's outdated.
+		if version == latest || version == local.main || minorRelease(version) != release {
+			continue
+		}
+		if err := remove(ctx, []string{version}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verify checks the integrity of the specified Go version's SDK.
+// Unlike the list command, verify does not rely on the .unpacked-success sentinel only,
+// it also makes sure that the go binary is executable and reports the expected version.
+func verify(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError{errors.New("no version has been specified")}
+	}
+
+	version := trimGo(args[0])
+	if !versionRE.MatchString(version) {
+		return malformedError{version}
+	}
+
+	local, err := localVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version == local.main:
+		return fmt.Errorf("unable to verify %s (main)", version)
+	case !local.contains(version):
+		return notFoundError{fmt.Errorf("%s is not installed", version)}
+	case !downloaded(version):
+		return fmt.Errorf("%s SDK is missing", version)
+	}
+
+	info, err := fs.Stat(sdk, exe("go"+version+"/bin/go"))
+	if err != nil {
+		return fmt.Errorf("%s SDK is broken: %w", version, err)
+	}
+	// there are no executable bits on Windows.
+	if !info.Mode().IsRegular() || runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("%s SDK is broken: go binary is not executable", version)
+	}
+
+	out, err := commandOutput(ctx, nil, "go"+version, "version")
+	if err != nil {
+		return fmt.Errorf("%s SDK is broken: %w", version, err)
+	}
+
+	// the format is `go version go1.18 darwin/arm64`, gotip reports a devel version instead.
+	parts := strings.Split(strings.TrimSpace(out), " ")
+	if version != "tip" && (len(parts) != 4 || parts[2] != "go"+version) {
+		return fmt.Errorf("%s SDK is broken: unexpected version %q", version, out)
+	}
+
+	// e.g. the SDK has been copied from an Intel Mac to an ARM one and runs under Rosetta.
+	if platform := parts[len(parts)-1]; platform != hostPlatform() {
+		return fmt.Errorf("%s SDK is built for %s, but the host is %s", version, platform, hostPlatform())
+	}
+
+	printf("%s SDK is OK\n", version)
+	return nil
+}
+
+// hostPlatform returns the GOOS/GOARCH pair of the host, e.g. darwin/arm64.
+func hostPlatform() string { return runtime.GOOS + "/" + runtime.GOARCH }
+
+// dlArch returns the architecture name go.dev uses for the archives of the given GOARCH:
+// they are the same, except for arm, which is published as armv6l.
+func dlArch(goarch string) string {
+	if goarch == "arm" {
+		return "armv6l"
+	}
+	return goarch
+}
+
+// sdkPlatform returns the GOOS/GOARCH pair the SDK of the specified Go version is built for.
+// If the SDK can't run on the host at all (e.g. exec format error), the error is returned.
+func sdkPlatform(ctx context.Context, version string) (string, error) {
+	out, err := commandOutput(ctx, nil, "go"+version, "version")
+	if err != nil {
+		return "", err
+	}
+	// the format is `go version go1.18 darwin/arm64`, the platform always goes last.
+	parts := strings.Fields(out)
+	if len(parts) < 4 {
+		return "", fmt.Errorf("unexpected format %q", out)
+	}
+	return parts[len(parts)-1], nil
+}
+
+// which prints the absolute path of the specified Go version's binary.
+// If the -sdk flag is provided, which prints the path of the SDK's go binary instead.
+// If the -json flag is provided, which prints the version along with the path in JSON format.
+// Like current, which writes to stdout, so it can be used in scripts.
+func which(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("which", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	var printSDK bool
+	fset.BoolVar(&printSDK, "sdk", false, "print the path of the SDK's go binary")
+
+	var printJSON bool
+	fset.BoolVar(&printJSON, "json", false, "print the version and the path in JSON format")
+
+	if err := fset.Parse(args); err != nil {
+		return usageError{err}
+	}
+
+	args = fset.Args()
+	if len(args) == 0 {
+		return usageError{errors.New("no version has been specified")}
+	}
+
+	local, err := localVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	version := trimGo(args[0])
+	if version == "main" {
+		version = local.main
+	}
+
+	if !versionRE.MatchString(version) {
+		return malformedError{version}
+	}
+
+	var path string
+	switch {
+	case !local.contains(version):
+		return notFoundError{fmt.Errorf("%s is not installed", version)}
+	case version == local.main:
+		// the main version lives outside of $GOBIN, so we need to look for it in $PATH.
+		env := envWithoutGOBIN(os.Environ())
+		if path, err = lookPath("go", getEnv(env, "PATH")); err != nil {
+			return err
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			return err
+		}
+	case printSDK:
+		if !downloaded(version) {
+			return fmt.Errorf("%s SDK is missing", version)
+		}
+		path = sdk.Path(exe("go" + version + "/bin/go"))
+	default:
+		path = gobin.Path(exe("go" + version))
+	}
+
+	if printJSON {
+		return json.NewEncoder(stdout).Encode(struct {
+			Version string `json:"version"`
+			Path    string `json:"path"`
+		}{version, path})
+	}
+
+	fmt.Fprintln(stdout, path)
+	return nil
+}
+
+// execute runs the given command with the specified Go version, without switching to it.
+// The version's SDK is prepended to $PATH for the command only.
+// If the version is not installed, execute will install it and download its SDK first.
+func execute(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("exec", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	var noChecksum bool
+	fset.BoolVar(&noChecksum, "no-checksum", false, "do not verify the downloaded SDK against the checksum from go.dev")
 
 	if err := fset.Parse(args); err != nil {
 		return usageError{err}
 	}
 
+	args = fset.Args()
+	if len(args) == 0 {
+		return usageError{errors.New("no version has been specified")}
+	}
+
+	version, cmd := trimGo(args[0]), args[1:]
+	if len(cmd) > 0 && cmd[0] == "--" {
+		cmd = cmd[1:]
+	}
+	if len(cmd) == 0 {
+		return usageError{errors.New("no command has been specified")}
+	}
+
 	local, err := localVersions(ctx)
 	if err != nil {
 		return err
 	}
 
-	versions := local.list
-	if printAll {
-		if versions, err = remoteVersions(ctx); err != nil {
+	if version == "main" {
+		version = local.main
+	}
+	version = resolveMinor(ctx, local, version) // the same way as use does it.
+
+	if !versionRE.MatchString(version) {
+		return malformedError{version}
+	}
+
+	env := os.Environ()
+	if version == local.main {
+		// the main version lives outside of $GOBIN, so cutting $GOBIN from $PATH is enough.
+		env = envWithoutGOBIN(env)
+	} else {
+		if err := installVersion(ctx, version, local.contains(version), noChecksum); err != nil {
 			return err
 		}
+		binDir := sdk.Path("go" + version + "/bin")
+		env = setEnv(env, "PATH", binDir+string(os.PathListSeparator)+getEnv(env, "PATH"))
 	}
 
-	for _, version := range versions {
-		if !strings.HasPrefix(version, only) {
-			continue
-		}
+	return command(withLiveOutput(ctx, stdout), env, cmd[0], cmd[1:]...)
+}
 
-		var extra string
-		switch {
-		case version == local.main:
-			extra = " (main)"
-		case !local.contains(version):
-			extra = " (not installed)"
-		case !downloaded(version):
-			extra = " (missing SDK)"
-		}
+// verifyChecksum verifies the downloaded SDK archive against the checksum published on go.dev.
+// If the verification fails, the SDK is removed, so it won't be used by accident.
+func verifyChecksum(ctx context.Context, version string) error {
+	remote, err := remoteVersions(ctx, true)
+	if err != nil {
+		return fmt.Errorf("unable to verify %s SDK checksum: %w", version, err)
+	}
 
-		prefix := " "
-		if version == local.current {
-			prefix = "*"
-		}
+	archive, ok := remote.archives[version]
+	if !ok {
+		// e.g. a mirror that only lists the versions (see $GOVERSION_DL_URL).
+		fmt.Fprintf(output, "Warning: no %s SDK archive for %s found on go.dev, skipping the checksum verification\n", version, hostPlatform())
+		return nil
+	}
+
+	// golang.org/dl keeps the archive in the SDK directory after unpacking.
+	f, err := sdk.Open("go" + version + "/" + archive.filename)
+	if err != nil {
+		return fmt.Errorf("unable to verify %s SDK checksum: %w", version, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("unable to verify %s SDK checksum: %w", version, err)
+	}
 
-		fmt.Fprintf(output, "%s %-10s%s\n", prefix, version, extra)
+	if got := hex.EncodeToString(hash.Sum(nil)); got != archive.sha256 {
+		if err := sdk.RemoveAll("go" + version); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s SDK checksum mismatch: want %s, got %s (the SDK has been removed)", version, archive.sha256, got)
 	}
 
+	logger.Printf("verified %s SDK checksum: %s", version, archive.sha256)
 	return nil
 }
 
-// remove removes the specified Go version (both the binary and the SDK).
-// If this version is current, remove will switch to the main one first.
-func remove(ctx context.Context, args []string) error {
-	if len(args) == 0 {
-		return usageError{errors.New("no version has been specified")}
+// current prints the current Go version without any decorations.
+// Unlike other commands, current writes to stdout, so it can be used in scripts.
+// If the -json flag is provided, current prints the version in JSON format.
+func current(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("current", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	var printJSON bool
+	fset.BoolVar(&printJSON, "json", false, "print the version in JSON format")
+
+	if err := fset.Parse(args); err != nil {
+		return usageError{err}
 	}
 
 	local, err := localVersions(ctx)
@@ -159,39 +1788,56 @@ func remove(ctx context.Context, args []string) error {
 		return err
 	}
 
-	version := args[0]
-	if version == "main" {
-		version = local.main
+	if local.dangling != "" {
+		return fmt.Errorf("the go symlink points to a missing binary (go%s), run `goversion repair` to fix it", local.dangling)
 	}
-
-	if !versionRE.MatchString(version) {
-		return fmt.Errorf("malformed version %q", version)
+	if local.current == unknownVersion {
+		return fmt.Errorf("the %s symlink doesn't point to a go<version> binary, switch to a version to fix it", linkName())
 	}
 
-	if !local.contains(version) {
-		return fmt.Errorf("%s is not installed", version)
+	if printJSON {
+		return json.NewEncoder(stdout).Encode(struct {
+			Version string `json:"version"`
+			Main    bool   `json:"main"`
+		}{local.current, local.current == local.main})
 	}
 
-	switch version {
-	case local.main:
-		return fmt.Errorf("unable to remove %s (main)", version)
-	case local.current:
-		// switch to the main version first.
-		if err := gobin.Remove("go"); err != nil {
+	fmt.Fprintln(stdout, local.current)
+	return nil
+}
+
+// downloadSDK downloads the SDK of the specified Go version.
+// If the download times out (see the -timeout flag) or is canceled (e.g. with Ctrl-C),
+// the partially downloaded SDK is removed, so the next run doesn't pick it up.
+func downloadSDK(ctx context.Context, version string) error {
+	err := command(ctx, nil, "go"+version, "download")
+	if err != nil && ctx.Err() != nil {
+		if err := removePartialSDK(version); err != nil {
 			return err
 		}
-		fmt.Fprintf(output, "Switched to %s (main)\n", local.main)
+		return fmt.Errorf("downloading %s SDK: %w", version, ctx.Err())
 	}
+	return err
+}
 
-	if err := gobin.Remove("go" + version); err != nil {
-		return err
-	}
-	if err := sdk.RemoveAll("go" + version); err != nil {
-		return err
+// reportDownload prints the size of the downloaded SDK and the time it took, so a slow mirror is easy to notice.
+// The download is performed by the go<version> binary, so the size is measured afterwards (best-effort).
+func reportDownload(version string, elapsed time.Duration) {
+	elapsed = elapsed.Round(time.Second)
+	size, err := sdkSize(version)
+	if err != nil {
+		logger.Printf("unable to measure %s SDK size: %v", version, err)
+		printf("Downloaded %s SDK in %s\n", version, elapsed)
+		return
 	}
+	printf("Downloaded %s SDK (%s in %s)\n", version, formatSize(size), elapsed)
+}
 
-	fmt.Fprintf(output, "Removed %s\n", version)
-	return nil
+// removePartialSDK removes what's left of the SDK whose download has been interrupted.
+// It's ok for the SDK directory to be missing.
+func removePartialSDK(version string) error {
+	logger.Printf("removing the partially downloaded %s SDK (if any)", version)
+	return sdk.RemoveAll("go" + version)
 }
 
 // downloaded checks whether the SDK of the specified Go version has been downloaded.
@@ -201,20 +1847,28 @@ func downloaded(version string) bool {
 	// version was downloaded and unpacked successfully.
 	name := "go" + version + "/.unpacked-success"
 	if version == "tip" {
-		name = "gotip/bin/go" // https://github.com/golang/dl/blob/master/internal/version/gotip.go#L45
+		name = exe("gotip/bin/go") // https://github.com/golang/dl/blob/master/internal/version/gotip.go#L45
 	}
 	_, err := fs.Stat(sdk, name)
+	logger.Printf("checking %s SDK: stat %s: %v", version, name, err)
 	return err == nil
 }
 
+// unknownVersion is the current version if the go symlink points to something other than a go<version> binary.
+const unknownVersion = "unknown"
+
 type local struct {
-	main    string
-	current string
-	list    []string // (includes both main and current).
+	main     string
+	current  string   // (empty if the go symlink is dangling, unknownVersion if it points to a foreign binary).
+	dangling string   // the version the go symlink points to if its binary is missing.
+	list     []string // (includes both main and current).
 }
 
-func (l *local) contains(version string) bool {
-	for _, v := range l.list {
+func (l *local) contains(version string) bool { return contains(l.list, version) }
+
+// contains reports whether the list contains the version.
+func contains(list []string, version string) bool {
+	for _, v := range list {
 		if v == version {
 			return true
 		}
@@ -222,35 +1876,124 @@ func (l *local) contains(version string) bool {
 	return false
 }
 
-// localVersions returns the list of installed Go versions.
-func localVersions(ctx context.Context) (*local, error) {
-	currPath := os.Getenv("PATH")
-	defer os.Setenv("PATH", currPath)
+// minorRE matches a minor release without a patch, e.g. 1.20.
+var minorRE = regexp.MustCompile(`^1\.[1-9][0-9]*$`)
 
-	// to make exec.Command use the main go binary,
-	// we need to temporary remove $GOBIN from $PATH.
-	tempPath := cutFromPath(currPath, os.Getenv("GOBIN"))
-	os.Setenv("PATH", tempPath)
+// latestInstalledPatch returns the latest installed stable patch of the given minor release
+// (e.g. 1.20.7 for 1.20 if both 1.20.3 and 1.20.7 are installed) or an empty string if there are none.
+func (l *local) latestInstalledPatch(minor string) string {
+	var latest string
+	for _, v := range l.list {
+		if minorRelease(v) != minor || strings.ContainsAny(v, "br") {
+			continue
+		}
+		if latest == "" || versionLess(v, latest) {
+			latest = v
+		}
+	}
+	return latest
+}
 
-	output, err := commandOutput(ctx, "go", "version")
-	if err != nil {
-		return nil, err
+// newestStable returns the newest installed stable version (including the main one)
+// or an empty string if there are none.
+func (l *local) newestStable() string {
+	var newest string
+	for _, v := range l.list {
+		if v == "tip" || strings.ContainsAny(v, "br") {
+			continue
+		}
+		if newest == "" || versionLess(v, newest) {
+			newest = v
+		}
+	}
+	return newest
+}
+
+// newestExcept returns the newest installed version with a downloaded SDK, except the main one, tip
+// and the given versions, or an empty string if there are none.
+func (l *local) newestExcept(versions []string) string {
+	var newest string
+	for _, v := range l.list {
+		if v == l.main || v == "tip" || contains(versions, v) || !downloaded(v) {
+			continue
+		}
+		if newest == "" || versionLess(v, newest) {
+			newest = v
+		}
 	}
+	return newest
+}
 
-	// the format is `go version go1.18 darwin/arm64`, we want the semver part.
-	parts := strings.Split(output, " ")
-	if len(parts) != 4 {
-		return nil, fmt.Errorf("unexpected format %q", output)
+// resolveMinor resolves a minor release without a patch (e.g. 1.20) to its latest installed patch
+// or, if there are none, to the latest patch available on go.dev.
+// Before Go 1.21, the first release of a minor had no patch (e.g. 1.20), so such a version is used as is
+// if it's installed itself or, when no patch is installed, if it's published on go.dev.
+// Any other version, as well as a minor release that can't be resolved, is returned as is.
+func resolveMinor(ctx context.Context, local *local, version string) string {
+	if !minorRE.MatchString(version) || local.contains(version) {
+		return version
+	}
+	if v := local.latestInstalledPatch(version); v != "" {
+		logger.Printf("resolved %s to the latest installed patch %s", version, v)
+		return v
 	}
+	remote, err := remoteVersions(ctx, true)
+	if err != nil {
+		logger.Printf("unable to resolve %s to the latest patch: %v", version, err)
+		return version
+	}
+	if contains(remote.list, version) {
+		return version
+	}
+	if v := remote.latestPatch(version); v != "" {
+		logger.Printf("resolved %s to the latest available patch %s", version, v)
+		return v
+	}
+	return version
+}
+
+// localVersions returns the list of installed Go versions.
+func localVersions(ctx context.Context) (*local, error) {
+	// to make exec.Command use the main go binary,
+	// we need to remove $GOBIN from its $PATH.
+	env := envWithoutGOBIN(os.Environ())
+	logger.Printf("cutting %s from $PATH to read the main version: %s", os.Getenv("GOBIN"), getEnv(env, "PATH"))
+
+	// spawning `go version` is the slowest part, so its result is cached (see goBinaryKey).
+	key := goBinaryKey(env)
+	main, current := "", ""
+	if main, _ = readCachedValue("main-version", key); main != "" {
+		logger.Printf("using the cached main version %s", main)
+	} else {
+		output, err := commandOutput(ctx, env, "go", "version")
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errNoMainGo
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	main, current := strings.TrimPrefix(parts[2], "go"), ""
+		// the format is `go version go1.18 darwin/arm64`, we want the semver part.
+		parts := strings.Split(output, " ")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("unexpected format %q", output)
+		}
+
+		main = strings.TrimPrefix(parts[2], "go")
+		writeCachedValue("main-version", key, main)
+	}
 
-	target, err := gobin.Readlink("go")
+	target, err := gobin.Readlink(linkName())
 	switch {
 	case errors.Is(err, fs.ErrNotExist):
 		current = main // the main version is already in use.
 	case err == nil:
 		current = strings.TrimPrefix(filepath.Base(target), "go")
+		// the symlink may have been repointed manually, e.g. to a Go installation outside of $GOBIN.
+		if name := filepath.Base(target); !strings.HasPrefix(name, "go") || !versionRE.MatchString(current) {
+			fmt.Fprintf(output, "Warning: the %s symlink points to %s, which is not a go<version> binary\n", linkName(), target)
+			current = unknownVersion
+		}
 	default:
 		return nil, err
 	}
@@ -260,89 +2003,540 @@ func localVersions(ctx context.Context) (*local, error) {
 		return nil, err
 	}
 
-	list := []string{main}
+	list, dangling := []string{main}, current != main && current != unknownVersion
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
 		}
-		version := strings.TrimPrefix(entry.Name(), "go")
+		// binaries have the .exe suffix on Windows.
+		version := strings.TrimPrefix(strings.TrimSuffix(entry.Name(), ".exe"), "go")
 		if versionRE.MatchString(version) {
 			list = append(list, version)
 		}
+		if version == current {
+			dangling = false
+		}
 	}
 
 	sort.Slice(list, func(i, j int) bool {
 		return versionLess(list[i], list[j])
 	})
 
-	return &local{
-		main:    main,
-		current: current,
-		list:    list,
-	}, nil
+	l := &local{main: main, current: current, list: list}
+
+	// the binary may have been removed manually, leaving the symlink dangling.
+	if dangling {
+		logger.Printf("the go symlink points to a missing binary: %s", target)
+		l.current, l.dangling = "", current
+	}
+
+	return l, nil
 }
 
 var httpClient interface {
 	Do(*http.Request) (*http.Response, error)
-} = &http.Client{Timeout: time.Minute}
+} = &http.Client{Timeout: defaultHTTPTimeout}
+
+// defaultHTTPTimeout is used if $GOVERSION_HTTP_TIMEOUT is not set.
+const defaultHTTPTimeout = time.Minute
+
+// httpTimeout returns the timeout from $GOVERSION_HTTP_TIMEOUT (e.g. 5m),
+// falling back to the default one if it's unset or malformed.
+func httpTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("GOVERSION_HTTP_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return defaultHTTPTimeout
+	}
+	return timeout
+}
+
+type remote struct {
+	list     []string // (includes both stable and unstable versions).
+	stable   []string
+	archives map[string]archive // the archives for the current platform by version.
+	releases []release          // the same versions as list with their metadata (except tip).
+}
+
+// release is a version published on go.dev along with its metadata.
+// Note that go.dev doesn't publish release dates.
+type release struct {
+	Version string        `json:"version"`
+	Kind    string        `json:"kind"` // stable, rc, beta or unstable.
+	Files   []releaseFile `json:"files"`
+}
+
+// releaseFile is a file of a release published on go.dev (an archive, an installer or the source code).
+type releaseFile struct {
+	Filename string `json:"filename"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Kind     string `json:"kind"`
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+}
+
+// release returns the metadata of the given version, if it's published on go.dev.
+func (r *remote) release(version string) (release, bool) {
+	for _, rel := range r.releases {
+		if rel.Version == version {
+			return rel, true
+		}
+	}
+	return release{}, false
+}
+
+// archive is an SDK archive published on go.dev.
+type archive struct {
+	filename string
+	sha256   string
+}
+
+// latest returns the latest stable version or an empty string if there are none.
+func (r *remote) latest() string {
+	var latest string
+	for _, v := range r.stable {
+		if latest == "" || versionLess(v, latest) {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// latestIn returns the latest version of the given channel (stable, rc or beta)
+// or an empty string if there are none.
+func (r *remote) latestIn(channel string) string {
+	if channel == "stable" {
+		return r.latest()
+	}
+	var latest string
+	for _, v := range r.list {
+		if _, _, tail := parseVersion(v); tail == "" {
+			continue
+		} else if kind, _ := parseTail(tail); kind != channel {
+			continue
+		}
+		if latest == "" || versionLess(v, latest) {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// supportedMinors returns the two latest stable minor releases (e.g. 1.22 and 1.21), newest first.
+// Go supports only these, the older ones are end-of-life.
+func (r *remote) supportedMinors() []string {
+	var minors []string
+	for _, v := range r.stable { // sorted from newest to oldest.
+		if m := minorRelease(v); !contains(minors, m) {
+			if minors = append(minors, m); len(minors) == 2 {
+				break
+			}
+		}
+	}
+	return minors
+}
+
+// latestPatch returns the latest stable patch of the given version's minor release
+// (e.g. 1.20.7 for 1.20.3) or an empty string if there are none.
+func (r *remote) latestPatch(version string) string {
+	var latest string
+	for _, v := range r.stable {
+		if minorRelease(v) != minorRelease(version) {
+			continue
+		}
+		if latest == "" || versionLess(v, latest) {
+			latest = v
+		}
+	}
+	return latest
+}
 
 // remoteVersions returns the list of all Go versions from go.dev.
-func remoteVersions(ctx context.Context) ([]string, error) {
-	const url = "https://go.dev/dl/?mode=json&include=all"
+// The base url can be overridden via $GOVERSION_DL_URL to use a mirror.
+// If useCache is true, the cached response is returned if it's not older than $GOVERSION_CACHE_TTL.
+func remoteVersions(ctx context.Context, useCache bool) (*remote, error) {
+	url := remoteURL()
+
+	var data []byte
+	if useCache {
+		var err error
+		if data, err = readCache(url); err != nil {
+			return nil, err
+		}
+	}
+
+	if data == nil {
+		resp, err := httpGet(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		// e.g. an HTML error page from a proxy or a captive portal.
+		// other content types are fine: static mirrors often serve the list as text/plain or application/octet-stream,
+		// and an invalid response fails to decode anyway.
+		if ct := resp.Header.Get("Content-Type"); strings.HasPrefix(ct, "text/html") {
+			return nil, fmt.Errorf("unexpected response from %s (content type %s)", resp.Request.URL.Host, ct)
+		}
+
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+
+		r, err := parseRemote(data)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected response from %s: %w", resp.Request.URL.Host, err)
+		}
+		writeCache(url, data) // only cache valid responses.
+		return r, nil
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	return parseRemote(data)
+}
+
+// cachedRemoteVersions returns the list of all Go versions from the cache, no matter how old it is,
+// along with the time it was cached. It's used as a fallback if go.dev is not reachable.
+func cachedRemoteVersions() (*remote, time.Time, error) {
+	data, modTime, err := readStaleCache(remoteURL())
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
+	}
+	if data == nil {
+		return nil, time.Time{}, errors.New("no cached list of versions")
 	}
 
-	resp, err := httpClient.Do(req)
+	r, err := parseRemote(data)
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
+	}
+
+	return r, modTime, nil
+}
+
+// quickRemoteTimeout limits the request made by quickRemoteVersions.
+const quickRemoteTimeout = time.Second
+
+type noRetriesKey struct{}
+
+// quickRemoteVersions is like remoteVersions, but the request (if the cached list is not fresh)
+// is a single attempt with a short timeout, so the best-effort checks run after a switch (warnEOL, warnOutdatedMain)
+// never stall it if go.dev is not reachable.
+func quickRemoteVersions(ctx context.Context) (*remote, error) {
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, noRetriesKey{}, true), quickRemoteTimeout)
+	defer cancel()
+	return remoteVersions(ctx, true)
+}
+
+// remoteURL returns the url of the list of all Go versions, see remoteVersions.
+func remoteURL() string {
+	return dlBaseURL() + "?mode=json&include=all"
+}
+
+// dlBaseURL returns the base url of the versions list and the SDK archives,
+// which can be overridden via $GOVERSION_DL_URL to use a mirror.
+// The url always ends with a slash, so the file names can be appended to it.
+func dlBaseURL() string {
+	if u := os.Getenv("GOVERSION_DL_URL"); u != "" {
+		return strings.TrimSuffix(u, "/") + "/"
 	}
-	defer resp.Body.Close()
+	return "https://go.dev/dl/"
+}
 
+// parseRemote parses the JSON response from go.dev.
+func parseRemote(data []byte) (*remote, error) {
 	// sorted by version, from newest to oldest.
 	var list []struct {
-		Version string `json:"version"`
-		Stable  bool   `json:"stable"`
+		Version string        `json:"version"`
+		Stable  bool          `json:"stable"`
+		Files   []releaseFile `json:"files"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+	if err := json.Unmarshal(data, &list); err != nil {
 		return nil, err
 	}
 
 	versions := make([]string, len(list)+1)
 	versions[0] = "tip" // the list does not include gotip, add it manually.
+
+	var stable []string
+	archives := make(map[string]archive)
+	releases := make([]release, len(list))
 	for i := 0; i < len(list); i++ {
-		versions[i+1] = strings.TrimPrefix(list[i].Version, "go")
+		version := strings.TrimPrefix(list[i].Version, "go")
+		versions[i+1] = version
+
+		kind := "unstable"
+		switch {
+		case list[i].Stable:
+			kind = "stable"
+			stable = append(stable, version)
+		case strings.Contains(version, "rc"):
+			kind = "rc"
+		case strings.Contains(version, "beta"):
+			kind = "beta"
+		}
+		releases[i] = release{Version: version, Kind: kind, Files: list[i].Files}
+
+		for _, f := range list[i].Files {
+			if f.Kind == "archive" && f.OS == runtime.GOOS && f.Arch == dlArch(runtime.GOARCH) {
+				archives[version] = archive{filename: f.Filename, sha256: f.SHA256}
+			}
+		}
+	}
+
+	return &remote{
+		list:     versions,
+		stable:   stable,
+		archives: archives,
+		releases: releases,
+	}, nil
+}
+
+// errNoMainGo is returned if there is no go binary in $PATH (e.g. on a fresh machine):
+// goversion installs other versions with `go install`, so it needs a Go installation to start with.
+var errNoMainGo error = notFoundError{errors.New("go is not found in $PATH, install Go from https://go.dev/doc/install first " +
+	"(goversion uses it to install other versions)")}
+
+// errNoNetwork is returned instead of accessing the network if $GOVERSION_NO_NETWORK is set.
+var errNoNetwork error = networkError{errors.New("network access is disabled by $GOVERSION_NO_NETWORK")}
+
+// networkDisabled reports whether $GOVERSION_NO_NETWORK is set to a true value (e.g. 1).
+func networkDisabled() bool {
+	disabled, _ := strconv.ParseBool(os.Getenv("GOVERSION_NO_NETWORK"))
+	return disabled
+}
+
+// errCustomSDKDir is returned instead of downloading an SDK if $GOVERSION_SDK_DIR is set,
+// since golang.org/dl always downloads SDKs to $HOME/sdk.
+var errCustomSDKDir = errors.New("golang.org/dl can't download SDKs to $GOVERSION_SDK_DIR, download it manually")
+
+// customSDKDir reports whether $GOVERSION_SDK_DIR is set.
+func customSDKDir() bool { return os.Getenv("GOVERSION_SDK_DIR") != "" }
+
+// linkName returns the name of the symlink to the current version in $GOBIN,
+// which is go by default and can be overridden via $GOVERSION_LINK_NAME (e.g. golang).
+func linkName() string {
+	if name := os.Getenv("GOVERSION_LINK_NAME"); name != "" {
+		return name
+	}
+	return "go"
+}
+
+// httpRetries returns the number of retries from $GOVERSION_HTTP_RETRIES or 2 if it's not set.
+func httpRetries() (int, error) {
+	s := os.Getenv("GOVERSION_HTTP_RETRIES")
+	if s == "" {
+		return 2, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid $GOVERSION_HTTP_RETRIES value %q", s)
+	}
+	return n, nil
+}
+
+// httpRetryDelay is the initial delay between retries, it's doubled after each attempt.
+var httpRetryDelay = time.Second
+
+// httpGet sends a GET request to the given url, retrying on network errors and 5xx responses.
+// The number of retries can be configured via $GOVERSION_HTTP_RETRIES (default is 2).
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	if networkDisabled() {
+		return nil, errNoNetwork
+	}
+
+	retries, err := httpRetries()
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Value(noRetriesKey{}) != nil {
+		retries = 0 // see quickRemoteVersions.
 	}
 
-	return versions, nil
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := httpClient.Do(req)
+		if err == nil {
+			switch {
+			case resp.StatusCode >= 500:
+				resp.Body.Close()
+				err = fmt.Errorf("unexpected response from %s (status %d)", req.URL.Host, resp.StatusCode)
+			case resp.StatusCode != http.StatusOK:
+				resp.Body.Close()
+				return nil, networkError{fmt.Errorf("unexpected response from %s (status %d)", req.URL.Host, resp.StatusCode)} // not worth retrying.
+			default:
+				return resp, nil
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if attempt == retries {
+			return nil, networkError{err}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(httpRetryDelay << attempt):
+		}
+	}
 }
 
 // cutFromPath cuts the given value from a $PATH-like string.
+// Both sides are cleaned before comparing, so e.g. a trailing slash doesn't matter.
 func cutFromPath(path, value string) string {
 	var list []string
 	for _, v := range strings.Split(path, string(os.PathListSeparator)) {
-		if v != value {
+		if !samePath(v, value) {
 			list = append(list, v)
 		}
 	}
 	return strings.Join(list, string(os.PathListSeparator))
 }
 
+// samePath reports whether the two paths are the same once cleaned (e.g. /go/bin/ and /go/bin).
+func samePath(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// envWithoutGOBIN returns a copy of the given environment with $GOBIN cut from $PATH,
+// so the go binary found in it is the main one.
+func envWithoutGOBIN(env []string) []string {
+	return setEnv(env, "PATH", cutFromPath(getEnv(env, "PATH"), getEnv(env, "GOBIN")))
+}
+
+// goInstallEnv returns the environment for installing golang.org/dl/go<version>:
+// $GOVERSION_GOPROXY, if set, overrides $GOPROXY only for this step.
+// A nil environment means the one of the current process is inherited.
+func goInstallEnv() []string {
+	proxy := os.Getenv("GOVERSION_GOPROXY")
+	if proxy == "" {
+		return nil
+	}
+	logger.Printf("using $GOVERSION_GOPROXY for go install: %s", proxy)
+	return setEnv(os.Environ(), "GOPROXY", proxy)
+}
+
+// getEnv returns the value of the key from the given environment.
+func getEnv(env []string, key string) string {
+	// the last value takes precedence, just like in exec.Cmd.
+	for i := len(env) - 1; i >= 0; i-- {
+		if k, v, ok := strings.Cut(env[i], "="); ok && sameEnvKey(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// setEnv returns a copy of the given environment with the key set to the value.
+func setEnv(env []string, key, value string) []string {
+	list := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if k, _, _ := strings.Cut(kv, "="); !sameEnvKey(k, key) {
+			list = append(list, kv)
+		}
+	}
+	return append(list, key+"="+value)
+}
+
+// sameEnvKey reports whether the keys are the same, they are case-insensitive on Windows.
+func sameEnvKey(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// lookPath is like exec.LookPath, but it searches the given $PATH-like string instead of $PATH.
+func lookPath(file, path string) (string, error) {
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "." // unix shell behavior.
+		}
+		name := filepath.Join(dir, exe(file))
+		info, err := os.Stat(name)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		// there are no executable bits on Windows.
+		if runtime.GOOS == "windows" || info.Mode().Perm()&0o111 != 0 {
+			return name, nil
+		}
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
+
+// newCommand is like exec.CommandContext, but if the environment is not nil,
+// the binary is looked for in its $PATH instead of the current one.
+func newCommand(ctx context.Context, env []string, name string, args ...string) (*exec.Cmd, error) {
+	if env != nil && !strings.ContainsAny(name, `/\`) {
+		path, err := lookPath(name, getEnv(env, "PATH"))
+		if err != nil {
+			return nil, err
+		}
+		name = path
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	return cmd, nil
+}
+
+// streamOutput makes command stream the output of the tools it runs (go install, go<version> download) live,
+// set by the -verbose flag.
+var streamOutput bool
+
+type liveOutputKey struct{}
+
+// withLiveOutput returns a copy of ctx that makes command always redirect the output to w (stdout) and stderr,
+// for the commands whose output is the point of running them (see execute).
+func withLiveOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, liveOutputKey{}, w)
+}
+
 // these are variables, so they can be mocked in tests.
 var (
-	// command is a wrapper for exec.Command.Run() that redirects stdout/stderr.
-	command = func(ctx context.Context, name string, args ...string) error {
-		cmd := exec.CommandContext(ctx, name, args...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		return cmd.Run()
+	// command is a wrapper for exec.Command.Run().
+	// The output of the command is buffered and printed only if it fails, unless it's streamed
+	// (see streamOutput and withLiveOutput), in which case stdin/stdout/stderr are redirected.
+	// If env is nil, the command inherits the current environment.
+	command = func(ctx context.Context, env []string, name string, args ...string) error {
+		logger.Printf("running %s %s", name, strings.Join(args, " "))
+		cmd, err := newCommand(ctx, env, name, args...)
+		if err != nil {
+			return err
+		}
+		cmd.Stdin = os.Stdin
+		if w, ok := ctx.Value(liveOutputKey{}).(io.Writer); ok || streamOutput {
+			cmd.Stdout = os.Stdout
+			if ok {
+				cmd.Stdout = w
+			}
+			cmd.Stderr = os.Stderr
+			return cmd.Run()
+		}
+		var buf bytes.Buffer
+		cmd.Stdout = &buf
+		cmd.Stderr = &buf
+		if err := cmd.Run(); err != nil {
+			_, _ = output.Write(buf.Bytes()) // best-effort: the error of the command is the one to report.
+			return err
+		}
+		return nil
 	}
 
 	// commandOutput is a wrapper for exec.Command.Output().
-	commandOutput = func(ctx context.Context, name string, args ...string) (string, error) {
-		cmd := exec.CommandContext(ctx, name, args...)
+	// If env is nil, the command inherits the current environment.
+	commandOutput = func(ctx context.Context, env []string, name string, args ...string) (string, error) {
+		logger.Printf("running %s %s", name, strings.Join(args, " "))
+		cmd, err := newCommand(ctx, env, name, args...)
+		if err != nil {
+			return "", err
+		}
 		out, err := cmd.Output()
 		return string(out), err
 	}
@@ -3,12 +3,21 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/go-simpler/assert"
 	. "github.com/go-simpler/assert/dotimport"
@@ -33,42 +42,191 @@ func Test_versionRE(t *testing.T) {
 	test("1.18.", false)
 	test("1.18.10", true)
 	test("1.18.10.", false)
+	test("1.21.0", true)
+	test("1.21.00", false)
+	test("1.21.01", false)
+	test("1.0", false)
+	test("1.01", false)
+	test("1.2.3.4", false)
+	test("1.21rc0", false)
+	test("1.21rc10", true)
+	test("go1.21", false)
 }
 
 const mainVersion = "1.19"
 
 var ctx = context.Background()
 
+// realCommand is the original command, before it's mocked by recordCommands.
+var realCommand = command
+
 func Test_use(t *testing.T) {
 	t.Run("install new version", func(t *testing.T) {
 		var steps []string
 		recordCommands(&steps)
 
 		gobin = &spyFS{dir: "gobin", calls: &steps}
-		sdk = &spyFS{dir: "sdk", calls: &steps}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/go1.18.tar.gz"}, // the archive is kept after unpacking.
+			calls: &steps,
+		}
 		output = io.Discard
 
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: archiveResponse("1.18", emptySHA256),
+		}
+
 		err := use(ctx, []string{"1.18"})
 		assert.NoErr[F](t, err)
 		assert.Equal[E](t, steps, []string{
-			"exec: go version",                             // 1. read main version
-			"call: gobin.Readlink(go)",                     // 2. read current version
-			"call: gobin.ReadDir(.)",                       // 3. read installed versions
-			"exec: go install golang.org/dl/go1.18@latest", // 4. install 1.18
-			"call: sdk.Stat(go1.18/.unpacked-success)",     // 5. check 1.18 SDK
-			"exec: go1.18 download",                        // 6. download 1.18 SDK
-			"call: gobin.Remove(go)",                       // 7. remove previous symlink
-			"call: gobin.Symlink(go1.18, go)",              // 8. create new symlink
+			"exec: go version",                               // 1. read main version
+			"call: gobin.Readlink(go)",                       // 2. read current version
+			"call: gobin.ReadDir(.)",                         // 3. read installed versions
+			"http: https://go.dev/dl/?mode=json&include=all", // 4. resolve 1.18 to its latest patch
+			"exec: go install golang.org/dl/go1.18@latest",   // 5. install 1.18
+			"call: sdk.Stat(go1.18/.unpacked-success)",       // 6. check 1.18 SDK
+			"call: sdk.RemoveAll(go1.18)",                    // 7. remove partial 1.18 SDK
+			"exec: go1.18 download",                          // 8. download 1.18 SDK
+			"call: sdk.Stat(go1.18)",                         // 9. measure 1.18 SDK size
+			"http: https://go.dev/dl/?mode=json&include=all", // 10. get 1.18 SDK checksum
+			"call: sdk.Open(go1.18/go1.18.tar.gz)",           // 11. verify 1.18 SDK checksum
+			"call: gobin.Symlink(go1.18, .go.tmp)",           // 12. create new symlink
+			"call: gobin.Rename(.go.tmp, go)",                // 13. replace previous symlink
+			"http: https://go.dev/dl/?mode=json&include=all", // 14. check if 1.18 is end-of-life
 		})
 	})
 
-	t.Run("switch to current version", func(t *testing.T) {
+	t.Run("install new version with checksum mismatch", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{dir: "gobin", calls: &steps}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/go1.18.tar.gz"},
+			calls: &steps,
+		}
+		output = io.Discard
+
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: archiveResponse("1.18", "bad"),
+		}
+
+		err := use(ctx, []string{"1.18"})
+		assert.Equal[F](t, err.Error(), "1.18 SDK checksum mismatch: want bad, got "+emptySHA256+" (the SDK has been removed)")
+		assert.Equal[E](t, steps[len(steps)-3:], []string{
+			"call: sdk.RemoveAll(go1.18)", // 1. remove mismatched 1.18 SDK
+			"call: sdk.RemoveAll(go1.18)", // 2. roll back 1.18 SDK
+			"call: gobin.Remove(go1.18)",  // 3. roll back 1.18 binary
+		})
+	})
+
+	t.Run("install new version without checksum on go.dev", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{dir: "gobin", calls: &steps}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		// e.g. a mirror that only lists the versions.
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"go1.18","stable":true}]`,
+		}
+
+		err := use(ctx, []string{"1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, strings.Contains(buf.String(), "Warning: no 1.18 SDK archive for "+hostPlatform()+" found on go.dev, "+
+			"skipping the checksum verification\n"), true)
+		assert.Equal[E](t, strings.HasSuffix(buf.String(), "Switched to 1.18\n"), true)
+	})
+
+	t.Run("install unknown version", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+		command = func(_ context.Context, _ []string, name string, args ...string) error {
+			steps = append(steps, "exec: "+strings.Join(append([]string{name}, args...), " "))
+			if len(args) > 0 && args[0] == "install" {
+				return errors.New("exit status 1")
+			}
+			return nil
+		}
+
+		gobin = &spyFS{dir: "gobin", calls: &steps}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+		httpClient = &httpSpy{requests: &steps, response: `[{"version":"go1.21.0","stable":true}]`}
+		output = io.Discard
+
+		err := use(ctx, []string{"1.99.1"})
+		assert.Equal[F](t, err.Error(), "1.99.1 is not found on go.dev")
+		assert.Equal[E](t, exitCode(err), exitNotFound)
+		assert.Equal[E](t, steps[3:], []string{
+			"exec: go install golang.org/dl/go1.99.1@latest", // 1. install 1.99.1
+			"http: https://go.dev/dl/?mode=json&include=all", // 2. check if 1.99.1 exists
+		})
+
+		// the version exists, so the failure is reported as is.
+		steps = nil
+		err = use(ctx, []string{"1.21.0"})
+		assert.Equal[F](t, err.Error(), "exit status 1")
+		assert.Equal[E](t, exitCode(err), exitFailure)
+	})
+
+	t.Run("roll back failed download", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+		command = func(_ context.Context, _ []string, name string, args ...string) error {
+			steps = append(steps, "exec: "+strings.Join(append([]string{name}, args...), " "))
+			if name == "go1.18" {
+				return errors.New("download failed")
+			}
+			return nil
+		}
+
+		gobin = &spyFS{dir: "gobin", calls: &steps}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+		httpClient = &httpSpy{requests: &steps, response: `[]`} // go.dev is queried to resolve 1.18.
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := use(ctx, []string{"1.18"})
+		assert.Equal[F](t, err.Error(), "download failed")
+		assert.Equal[E](t, buf.String(), "1.18 is not installed. Looking for it on go.dev ...\n"+
+			"Rolled back the incomplete 1.18 installation\n")
+		assert.Equal[E](t, steps[4:], []string{
+			"exec: go install golang.org/dl/go1.18@latest", // 1. install 1.18
+			"call: sdk.Stat(go1.18/.unpacked-success)",     // 2. check 1.18 SDK
+			"call: sdk.RemoveAll(go1.18)",                  // 3. remove partial 1.18 SDK
+			"exec: go1.18 download",                        // 4. download 1.18 SDK
+			"call: sdk.RemoveAll(go1.18)",                  // 5. roll back 1.18 SDK
+			"call: gobin.Remove(go1.18)",                   // 6. roll back 1.18 binary
+		})
+
+		// the binary has been installed before, so it's kept.
+		gobin = &spyFS{dir: "gobin", files: []dirFile{"go1.18"}, calls: &steps}
+		steps = nil
+		err = use(ctx, []string{"1.18"})
+		assert.Equal[F](t, err.Error(), "download failed")
+		assert.Equal[E](t, steps[len(steps)-1], "call: sdk.RemoveAll(go1.18)")
+	})
+
+	t.Run("quiet", func(t *testing.T) {
+		t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
+		defer func() { quiet = false }()
+		quiet = true
+
 		var steps []string
 		recordCommands(&steps)
 
 		gobin = &spyFS{
 			dir:   "gobin",
-			link:  "/path/to/go1.18",
 			files: []dirFile{"go1.18"},
 			calls: &steps,
 		}
@@ -83,15 +241,11 @@ func Test_use(t *testing.T) {
 
 		err := use(ctx, []string{"1.18"})
 		assert.NoErr[F](t, err)
-		assert.Equal[E](t, buf.String(), "1.18 is already in use\n")
-		assert.Equal[E](t, steps, []string{
-			"exec: go version",         // 1. read main version
-			"call: gobin.Readlink(go)", // 2. read current version
-			"call: gobin.ReadDir(.)",   // 3. read installed versions
-		})
+		assert.Equal[E](t, buf.String(), "")
+		assert.Equal[E](t, steps[len(steps)-2:], []string{"call: gobin.Symlink(go1.18, .go.tmp)", "call: gobin.Rename(.go.tmp, go)"})
 	})
 
-	t.Run("switch to main version", func(t *testing.T) {
+	t.Run("switch to current version", func(t *testing.T) {
 		var steps []string
 		recordCommands(&steps)
 
@@ -110,61 +264,50 @@ func Test_use(t *testing.T) {
 		var buf bytes.Buffer
 		output = &buf
 
-		err := use(ctx, []string{"main"})
+		err := use(ctx, []string{"1.18"})
 		assert.NoErr[F](t, err)
-		assert.Equal[E](t, buf.String(), "Switched to 1.19 (main)\n")
+		assert.Equal[E](t, buf.String(), "1.18 is already in use\n")
 		assert.Equal[E](t, steps, []string{
 			"exec: go version",         // 1. read main version
 			"call: gobin.Readlink(go)", // 2. read current version
 			"call: gobin.ReadDir(.)",   // 3. read installed versions
-			"call: gobin.Remove(go)",   // 4. remove symlink (switch to main)
 		})
+
+		buf.Reset()
+		err = use(ctx, []string{"-quiet-if-current", "1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "")
 	})
-}
 
-func Test_list(t *testing.T) {
-	t.Run("list local versions", func(t *testing.T) {
+	t.Run("switch to current version if changed", func(t *testing.T) {
 		var steps []string
 		recordCommands(&steps)
 
 		gobin = &spyFS{
 			dir:   "gobin",
 			link:  "/path/to/go1.18",
-			files: []dirFile{"go1.17", "go1.18"},
-			calls: &steps,
-		}
-		sdk = &spyFS{
-			dir:   "sdk",
-			files: []dirFile{"go1.18/.unpacked-success"}, // 1.17 SDK is missing.
+			files: []dirFile{"go1.18"},
 			calls: &steps,
 		}
 
 		var buf bytes.Buffer
 		output = &buf
 
-		err := list(ctx, nil)
+		err := use(ctx, []string{"-if-changed", "1.18"})
 		assert.NoErr[F](t, err)
-		assert.Equal[E](t, "\n"+buf.String(), `
-  1.19       (main)
-* 1.18      
-  1.17       (missing SDK)
-`)
+		assert.Equal[E](t, buf.String(), "")
 		assert.Equal[E](t, steps, []string{
-			"exec: go version",                         // 1. read main version
-			"call: gobin.Readlink(go)",                 // 2. read current version
-			"call: gobin.ReadDir(.)",                   // 3. read installed versions
-			"call: sdk.Stat(go1.18/.unpacked-success)", // 4. check 1.18 SDK
-			"call: sdk.Stat(go1.17/.unpacked-success)", // 5. check 1.17 SDK
+			"call: gobin.Readlink(go)", // 1. read current version (fast path)
+			"call: gobin.Stat(go1.18)", // 2. make sure the symlink is not dangling
 		})
 	})
 
-	t.Run("list remote versions", func(t *testing.T) {
+	t.Run("print path", func(t *testing.T) {
 		var steps []string
 		recordCommands(&steps)
 
 		gobin = &spyFS{
 			dir:   "gobin",
-			link:  "/path/to/go1.18",
 			files: []dirFile{"go1.18"},
 			calls: &steps,
 		}
@@ -174,40 +317,82 @@ func Test_list(t *testing.T) {
 			calls: &steps,
 		}
 
+		var buf bytes.Buffer
+		stdout = &buf
+
+		err := use(ctx, []string{"-print-path", "1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "export PATH=sdk/go1.18/bin:$PATH\n")
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",                         // 1. read main version
+			"call: gobin.Readlink(go)",                 // 2. read current version
+			"call: gobin.ReadDir(.)",                   // 3. read installed versions
+			"call: sdk.Stat(go1.18/.unpacked-success)", // 4. check 1.18 SDK
+		})
+	})
+
+	t.Run("no switch", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{dir: "gobin", calls: &steps}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+		httpClient = &httpSpy{requests: &steps, response: `[]`} // go.dev is queried to resolve 1.18.
+
 		var buf bytes.Buffer
 		output = &buf
 
-		httpClient = &httpSpy{
-			requests: &steps,
-			response: `[{"version":"1.19"},{"version":"1.18"},{"version":"1.17"}]`,
-		}
+		err := use(ctx, []string{"-no-switch", "-no-checksum", "1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "1.18 is not installed. Looking for it on go.dev ...\nDownloaded 1.18 SDK in 0s\n1.18 is ready\n")
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",                               // 1. read main version
+			"call: gobin.Readlink(go)",                       // 2. read current version
+			"call: gobin.ReadDir(.)",                         // 3. read installed versions
+			"http: https://go.dev/dl/?mode=json&include=all", // 4. resolve 1.18 to its latest patch
+			"exec: go install golang.org/dl/go1.18@latest",   // 5. install 1.18
+			"call: sdk.Stat(go1.18/.unpacked-success)",       // 6. check 1.18 SDK
+			"call: sdk.RemoveAll(go1.18)",                    // 7. remove partial 1.18 SDK
+			"exec: go1.18 download",                          // 8. download 1.18 SDK
+			"call: sdk.Stat(go1.18)",                         // 9. measure 1.18 SDK size
+		})
+	})
 
-		err := list(ctx, []string{"-all"})
+	t.Run("dry run", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{dir: "gobin", calls: &steps}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+		httpClient = &httpSpy{requests: &steps, response: `[]`} // go.dev is queried to resolve 1.18.
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := use(ctx, []string{"-dry-run", "1.18"})
 		assert.NoErr[F](t, err)
 		assert.Equal[E](t, "\n"+buf.String(), `
-  tip        (not installed)
-  1.19       (main)
-* 1.18      
-  1.17       (not installed)
+[dry-run] 1.18 is not installed. Looking for it on go.dev ...
+[dry-run] Downloading 1.18 SDK ...
+[dry-run] Switched to 1.18
 `)
 		assert.Equal[E](t, steps, []string{
 			"exec: go version",                               // 1. read main version
 			"call: gobin.Readlink(go)",                       // 2. read current version
 			"call: gobin.ReadDir(.)",                         // 3. read installed versions
-			"http: https://go.dev/dl/?mode=json&include=all", // 4. get remote versions
+			"http: https://go.dev/dl/?mode=json&include=all", // 4. resolve 1.18 to its latest patch
 			"call: sdk.Stat(go1.18/.unpacked-success)",       // 5. check 1.18 SDK
 		})
 	})
-}
 
-func Test_remove(t *testing.T) {
-	t.Run("remove existing version", func(t *testing.T) {
+	t.Run("replace dangling symlink", func(t *testing.T) {
+		t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
 		var steps []string
 		recordCommands(&steps)
 
 		gobin = &spyFS{
 			dir:   "gobin",
-			link:  "/path/to/go1.18",
+			link:  "/path/to/go1.17", // go1.17 has been removed manually.
 			files: []dirFile{"go1.18"},
 			calls: &steps,
 		}
@@ -216,27 +401,25 @@ func Test_remove(t *testing.T) {
 			files: []dirFile{"go1.18/.unpacked-success"},
 			calls: &steps,
 		}
-		output = io.Discard
 
-		err := remove(ctx, []string{"1.18"})
+		var buf bytes.Buffer
+		output = &buf
+
+		err := use(ctx, []string{"1.18"})
 		assert.NoErr[F](t, err)
-		assert.Equal[E](t, steps, []string{
-			"exec: go version",            // 1. read main version
-			"call: gobin.Readlink(go)",    // 2. read current version
-			"call: gobin.ReadDir(.)",      // 3. read installed versions
-			"call: gobin.Remove(go)",      // 4. remove symlink (switch to main)
-			"call: gobin.Remove(go1.18)",  // 4. remove 1.18 binary
-			"call: sdk.RemoveAll(go1.18)", // 5. remove 1.18 SDK
-		})
+		assert.Equal[E](t, buf.String(), "The go symlink points to a missing binary (go1.17), it will be replaced\nSwitched to 1.18\n")
+		assert.Equal[E](t, steps[len(steps)-2:], []string{"call: gobin.Symlink(go1.18, .go.tmp)", "call: gobin.Rename(.go.tmp, go)"})
 	})
 
-	t.Run("remove non-existing version", func(t *testing.T) {
+	t.Run("custom link name", func(t *testing.T) {
+		t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
+		t.Setenv("GOVERSION_LINK_NAME", "golang")
+
 		var steps []string
 		recordCommands(&steps)
 
 		gobin = &spyFS{
 			dir:   "gobin",
-			link:  "/path/to/go1.18",
 			files: []dirFile{"go1.18"},
 			calls: &steps,
 		}
@@ -247,69 +430,2661 @@ func Test_remove(t *testing.T) {
 		}
 		output = io.Discard
 
-		err := remove(ctx, []string{"1.17"})
-		assert.Equal[F](t, err.Error(), "1.17 is not installed")
+		err := use(ctx, []string{"1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",                         // 1. read main version
+			"call: gobin.Readlink(golang)",             // 2. read current version
+			"call: gobin.ReadDir(.)",                   // 3. read installed versions
+			"call: sdk.Stat(go1.18/.unpacked-success)", // 4. check 1.18 SDK
+			"call: gobin.Symlink(go1.18, .golang.tmp)", // 5. create new symlink
+			"call: gobin.Rename(.golang.tmp, golang)",  // 6. replace previous symlink
+		})
+	})
+
+	t.Run("install new version offline", func(t *testing.T) {
+		t.Setenv("GOVERSION_NO_NETWORK", "1")
+
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{dir: "gobin", calls: &steps}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+		output = io.Discard
+
+		err := use(ctx, []string{"1.18"})
+		assert.IsErr[F](t, err, errNoNetwork)
 		assert.Equal[E](t, steps, []string{
 			"exec: go version",         // 1. read main version
 			"call: gobin.Readlink(go)", // 2. read current version
 			"call: gobin.ReadDir(.)",   // 3. read installed versions
 		})
 	})
-}
-
-func recordCommands(commands *[]string) {
-	command = func(ctx context.Context, name string, args ...string) error {
-		c := strings.Join(append([]string{name}, args...), " ")
-		*commands = append(*commands, "exec: "+c)
-		return nil
-	}
-	commandOutput = func(ctx context.Context, name string, args ...string) (string, error) {
-		_ = command(ctx, name, args...)
-		return fmt.Sprintf("go version go%s darwin/arm64", mainVersion), nil
-	}
-}
 
-type spyFS struct {
-	dir   string
-	link  string
-	files []dirFile
-	calls *[]string
-}
+	t.Run("download SDK to custom directory", func(t *testing.T) {
+		t.Setenv("GOVERSION_SDK_DIR", "/path/to/sdk")
 
-func (s *spyFS) Open(name string) (fs.File, error) {
-	// won't be called because Stat is implemented.
-	panic("unimplemented")
-}
+		var steps []string
+		recordCommands(&steps)
 
-func (s *spyFS) Stat(name string) (fs.FileInfo, error) {
-	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Stat(%s)", s.dir, name))
-	for _, f := range s.files {
-		if string(f) == name {
-			return nil, nil
+		gobin = &spyFS{
+			dir:   "gobin",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
 		}
-	}
-	return nil, fs.ErrNotExist
-}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+		output = io.Discard
 
-func (s *spyFS) Remove(name string) error {
-	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Remove(%s)", s.dir, name))
-	return nil
-}
+		err := use(ctx, []string{"1.18"})
+		assert.IsErr[F](t, err, errCustomSDKDir)
+	})
 
-func (s *spyFS) RemoveAll(name string) error {
-	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.RemoveAll(%s)", s.dir, name))
-	return nil
-}
+	t.Run("re-download current version", func(t *testing.T) {
+		httpClient = &httpSpy{requests: new([]string), response: `[]`} // for the end-of-life check.
+		var steps []string
+		recordCommands(&steps)
 
-func (s *spyFS) Symlink(oldname, newname string) error {
-	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Symlink(%s, %s)", s.dir, oldname, newname))
-	return nil
-}
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{dir: "sdk", calls: &steps} // the removed SDK is missing.
+		output = io.Discard
 
-func (s *spyFS) Readlink(name string) (string, error) {
-	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Readlink(%s)", s.dir, name))
-	if s.link == "" {
-		return "", fs.ErrNotExist
+		err := use(ctx, []string{"-force", "-no-checksum", "1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",                         // 1. read main version
+			"call: gobin.Readlink(go)",                 // 2. read current version
+			"call: gobin.ReadDir(.)",                   // 3. read installed versions
+			"call: sdk.RemoveAll(go1.18)",              // 4. remove 1.18 SDK
+			"call: sdk.Stat(go1.18/.unpacked-success)", // 5. check 1.18 SDK
+			"exec: go1.18 download",                    // 6. download 1.18 SDK
+			"call: sdk.Stat(go1.18)",                   // 7. measure 1.18 SDK size
+			"call: gobin.Symlink(go1.18, .go.tmp)",     // 8. create new symlink
+			"call: gobin.Rename(.go.tmp, go)",          // 9. replace previous symlink
+		})
+	})
+
+	t.Run("switch to main version", func(t *testing.T) {
+		t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the outdated main check.
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success"},
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := use(ctx, []string{"main"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.19 (main)\n")
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",         // 1. read main version
+			"call: gobin.Readlink(go)", // 2. read current version
+			"call: gobin.ReadDir(.)",   // 3. read installed versions
+			"call: gobin.Remove(go)",   // 4. remove symlink (switch to main)
+		})
+	})
+	t.Run("switch to go-prefixed version", func(t *testing.T) {
+		t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success"},
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := use(ctx, []string{"go1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.18\n")
+		assert.Equal[E](t, steps[len(steps)-2:], []string{"call: gobin.Symlink(go1.18, .go.tmp)", "call: gobin.Rename(.go.tmp, go)"})
+	})
+
+	t.Run("switch to previous version", func(t *testing.T) {
+		t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success"},
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := savePrevious("1.18")
+		assert.NoErr[F](t, err)
+
+		err = use(ctx, []string{"-"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.18\n")
+
+		previous, err := loadPrevious()
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, previous, "1.19")
+	})
+
+	t.Run("switch to tip", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{dir: "gobin", calls: &steps}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := use(ctx, []string{"tip"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "tip is not installed. Looking for it on go.dev ...\n"+
+			"Downloaded tip SDK in 0s\n"+
+			"Switched to tip\n")
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",                            // 1. read main version
+			"call: gobin.Readlink(go)",                    // 2. read current version
+			"call: gobin.ReadDir(.)",                      // 3. read installed versions
+			"exec: go install golang.org/dl/gotip@latest", // 4. install tip
+			"call: sdk.Stat(gotip/bin/go)",                // 5. check tip SDK
+			"call: sdk.RemoveAll(gotip)",                  // 6. remove partial tip SDK
+			"exec: gotip download",                        // 7. build tip from source
+			"call: sdk.Stat(gotip)",                       // 8. measure tip SDK size
+			"call: gobin.Symlink(gotip, .go.tmp)",         // 9. create new symlink
+			"call: gobin.Rename(.go.tmp, go)",             // 10. replace previous symlink
+		})
+	})
+
+	t.Run("switch to latest version", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.18", "go1.19.1"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success", "go1.19.1/.unpacked-success"},
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"go1.20rc1","stable":false},{"version":"go1.19.1","stable":true},{"version":"go1.19","stable":true}]`,
+		}
+
+		err := use(ctx, []string{"latest"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.19.1\n")
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",                               // 1. read main version
+			"call: gobin.Readlink(go)",                       // 2. read current version
+			"call: gobin.ReadDir(.)",                         // 3. read installed versions
+			"http: https://go.dev/dl/?mode=json&include=all", // 4. get remote versions
+			"call: sdk.Stat(go1.19.1/.unpacked-success)",     // 5. check 1.19.1 SDK
+			"call: gobin.Symlink(go1.19.1, .go.tmp)",         // 6. create new symlink
+			"call: gobin.Rename(.go.tmp, go)",                // 7. replace previous symlink
+			"http: https://go.dev/dl/?mode=json&include=all", // 8. check if 1.19.1 is end-of-life
+		})
+	})
+
+	t.Run("switch to end-of-life version", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success"},
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"go1.21.1","stable":true},{"version":"go1.21","stable":true},` +
+				`{"version":"go1.20","stable":true},{"version":"go1.18","stable":true}]`,
+		}
+
+		err := use(ctx, []string{"1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.18\n"+
+			"Warning: 1.18 is end-of-life and no longer supported (the supported releases are 1.21 and 1.20)\n")
+
+		// the check is skipped in the offline mode.
+		t.Setenv("GOVERSION_NO_NETWORK", "1")
+		buf.Reset()
+		err = use(ctx, []string{"1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.18\n")
+	})
+
+	t.Run("switch to outdated main version", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"go1.20","stable":true},{"version":"go1.19","stable":true}]`,
+		}
+
+		err := use(ctx, []string{"main"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.19 (main)\n"+
+			"Warning: 1.19 (main) is behind the latest stable version 1.20, "+
+			"update it from https://go.dev/dl or with the package manager it was installed with\n")
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",                               // 1. read main version
+			"call: gobin.Readlink(go)",                       // 2. read current version
+			"call: gobin.ReadDir(.)",                         // 3. read installed versions
+			"call: gobin.Remove(go)",                         // 4. remove symlink
+			"http: https://go.dev/dl/?mode=json&include=all", // 5. check if 1.19 (main) is outdated
+		})
+	})
+
+	t.Run("switch to latest installed version", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.18", "go1.20.3", "go1.21rc1", "gotip"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success", "go1.20.3/.unpacked-success"},
+			calls: &steps,
+		}
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"go1.21.0","stable":true}]`,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := use(ctx, []string{"-prefer-installed", "1.18"})
+		assert.Equal[E](t, errors.As(err, new(usageError)), true)
+
+		err = use(ctx, []string{"-prefer-installed", "-channel=rc", "latest"})
+		assert.Equal[E](t, errors.As(err, new(usageError)), true)
+
+		steps = nil
+		err = use(ctx, []string{"-prefer-installed", "latest"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.20.3\n")
+		for _, s := range steps {
+			if strings.HasPrefix(s, "http: ") {
+				t.Errorf("unexpected request: %s", s)
+			}
+		}
+
+		assert.Equal[E](t, (&local{list: []string{"tip", "1.21rc1"}}).newestStable(), "")
+		assert.Equal[E](t, (&local{list: []string{"1.19", "1.20.3", "1.18"}}).newestStable(), "1.20.3")
+	})
+
+	t.Run("run post-use hook", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		var env []string
+		record := command
+		command = func(ctx context.Context, e []string, name string, args ...string) error {
+			if name == shellCommand("")[0] {
+				env = e
+				steps = append(steps, "hook: "+strings.Join(args, " "))
+				return errors.New("exit status 1")
+			}
+			return record(ctx, e, name, args...)
+		}
+
+		gobin = &spyFS{dir: "gobin", link: "/path/to/go1.18", files: []dirFile{"go1.18", "go1.20"}, calls: &steps}
+		sdk = &spyFS{dir: "sdk", files: []dirFile{"go1.18/.unpacked-success", "go1.20/.unpacked-success"}, calls: &steps}
+		t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
+
+		var buf bytes.Buffer
+		output = &buf
+
+		t.Setenv("GOVERSION_POST_USE", "go install example.com/tool@latest")
+
+		t.Run("already in use", func(t *testing.T) {
+			steps = nil
+			err := use(ctx, []string{"1.18"})
+			assert.NoErr[F](t, err)
+			for _, s := range steps {
+				if strings.HasPrefix(s, "hook: ") {
+					t.Errorf("unexpected hook run: %s", s)
+				}
+			}
+		})
+
+		t.Run("switch", func(t *testing.T) {
+			steps, env = nil, nil
+			buf.Reset()
+			err := use(ctx, []string{"1.20"})
+			assert.NoErr[F](t, err)
+			assert.Equal[E](t, steps[len(steps)-1], "hook: "+strings.Join(shellCommand("go install example.com/tool@latest")[1:], " "))
+			assert.Equal[E](t, getEnv(env, "GOVERSION_OLD"), "1.18")
+			assert.Equal[E](t, getEnv(env, "GOVERSION_NEW"), "1.20")
+			assert.Equal[E](t, buf.String(), "Switched to 1.20\nWarning: the post-use hook failed: exit status 1\n")
+		})
+	})
+
+	t.Run("print JSON events", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{dir: "gobin", link: "/path/to/go1.17", files: []dirFile{"go1.17"}, calls: &steps}
+		sdk = &spyFS{dir: "sdk", files: []dirFile{"go1.17/.unpacked-success"}, calls: &steps}
+		httpClient = &httpSpy{requests: &steps, response: `[]`}
+
+		var out, buf bytes.Buffer
+		stdout, output = &out, &buf
+
+		err := use(ctx, []string{"-json", "-no-checksum", "1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "") // no human-readable messages.
+		assert.Equal[E](t, "\n"+out.String(), `
+{"event":"installing","version":"1.18"}
+{"event":"downloading","version":"1.18"}
+{"event":"switched","version":"1.18"}
+`)
+		assert.Equal[E](t, quiet, false) // restored.
+
+		out.Reset()
+		err = use(ctx, []string{"-json", "1.17"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, out.String(), `{"event":"unchanged","version":"1.17"}`+"\n")
+
+		err = use(ctx, []string{"-json", "-dry-run", "1.18"})
+		assert.Equal[E](t, errors.As(err, new(usageError)), true)
+
+		// the frontend is gone, e.g. the pipe is closed.
+		closed, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+		assert.NoErr[F](t, err)
+		assert.NoErr[F](t, closed.Close())
+		stdout = closed
+		err = use(ctx, []string{"-json", "1.17"})
+		assert.IsErr[E](t, err, os.ErrClosed)
+		stdout = &out
+
+		t.Run("post-use hook", func(t *testing.T) {
+			if runtime.GOOS == "windows" {
+				t.Skip("the hook is run with cmd /C on Windows")
+			}
+			record := command
+			defer func() { command = record }()
+			command = func(ctx context.Context, env []string, name string, args ...string) error {
+				if name == "sh" {
+					return realCommand(ctx, env, name, args...) // the hook actually runs.
+				}
+				return record(ctx, env, name, args...)
+			}
+			t.Setenv("GOVERSION_POST_USE", "echo hook-output")
+			t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
+
+			gobin = &spyFS{dir: "gobin", link: "/path/to/go1.18", files: []dirFile{"go1.17", "go1.18"}, calls: &steps}
+
+			out.Reset()
+			buf.Reset()
+			err := use(ctx, []string{"-json", "1.17"})
+			assert.NoErr[F](t, err)
+			assert.Equal[E](t, out.String(), `{"event":"switched","version":"1.17"}`+"\n")
+			assert.Equal[E](t, buf.String(), "hook-output\n")
+		})
+	})
+
+	t.Run("replace foreign symlink", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		sdk = &spyFS{dir: "sdk", files: []dirFile{"go1.18/.unpacked-success"}, calls: &steps}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		for _, target := range []string{"/usr/local/go/bin/go", "/usr/bin/python3", "/path/to/go1.18-custom"} {
+			buf.Reset()
+			gobin = &spyFS{dir: "gobin", link: target, files: []dirFile{"go1.18"}, calls: &steps}
+
+			local, err := localVersions(ctx)
+			assert.NoErr[F](t, err)
+			assert.Equal[E](t, local.current, unknownVersion)
+			assert.Equal[E](t, local.dangling, "")
+			assert.Equal[E](t, local.list, []string{"1.19", "1.18"})
+			assert.Equal[E](t, buf.String(), "Warning: the go symlink points to "+target+", which is not a go<version> binary\n")
+		}
+
+		// switching to a version repoints the symlink.
+		t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
+		buf.Reset()
+		err := use(ctx, []string{"1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, strings.HasSuffix(buf.String(), "Switched to 1.18\n"), true)
+
+		err = lockfile(ctx, nil)
+		assert.Equal[E](t, err != nil, true)
+	})
+}
+
+func Test_remoteLatestIn(t *testing.T) {
+	remote := &remote{
+		list:   []string{"tip", "1.21rc2", "1.21rc10", "1.21beta1", "1.20.1", "1.20", "1.20rc1", "1.20beta2"},
+		stable: []string{"1.20.1", "1.20"},
+	}
+
+	assert.Equal[E](t, remote.latestIn("stable"), "1.20.1")
+	assert.Equal[E](t, remote.latestIn("rc"), "1.21rc10")
+	assert.Equal[E](t, remote.latestIn("beta"), "1.21beta1")
+
+	remote.list = []string{"tip", "1.20"}
+	assert.Equal[E](t, remote.latestIn("rc"), "")
+}
+
+func Test_useChannel(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{dir: "gobin", link: "/path/to/go1.18", files: []dirFile{"go1.18"}, calls: &steps}
+	sdk = &spyFS{dir: "sdk", files: []dirFile{"go1.18/.unpacked-success"}, calls: &steps}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err := use(ctx, []string{"-channel=rc", "1.18"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+
+	err = use(ctx, []string{"-channel=nightly", "latest"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.19","stable":true}]`,
+	}
+
+	err = use(ctx, []string{"-channel=beta", "latest"})
+	assert.Equal[E](t, errors.As(err, new(notFoundError)), true)
+	assert.Equal[E](t, err.Error(), "no beta version found on go.dev")
+}
+
+func Test_trimGo(t *testing.T) {
+	test := func(version, want string) {
+		t.Helper()
+		assert.Equal[E](t, trimGo(version), want)
+	}
+
+	test("1.21", "1.21")
+	test("go1.21", "1.21")
+	test("go1.21rc1", "1.21rc1")
+	test("gotip", "tip")
+	test("golden", "golden") // not a version, e.g. an alias.
+}
+
+func Test_resolveMinor(t *testing.T) {
+	var requests []string
+	httpClient = &httpSpy{
+		requests: &requests,
+		response: `[{"version":"go1.21.2","stable":true,"files":[]},` +
+			`{"version":"go1.21.1","stable":true,"files":[]},` +
+			`{"version":"go1.22rc1","stable":false,"files":[]},` +
+			`{"version":"go1.18.5","stable":true,"files":[]},` +
+			`{"version":"go1.18","stable":true,"files":[]},` +
+			`{"version":"go1.16.2","stable":true,"files":[]},` +
+			`{"version":"go1.16","stable":true,"files":[]}]`,
+	}
+
+	local := &local{main: "1.19", list: []string{"1.19", "1.20", "1.20.3", "1.20.7", "1.21rc2", "1.18.5", "1.18.2", "1.17.3"}}
+
+	test := func(version, want string) {
+		t.Helper()
+		assert.Equal[E](t, resolveMinor(ctx, local, version), want)
+	}
+
+	test("1.20", "1.20")     // installed, as is.
+	test("1.19", "1.19")     // the main version.
+	test("1.18", "1.18.5")   // the latest installed patch (even though 1.18 is published on go.dev).
+	test("1.17", "1.17.3")   // the latest installed patch.
+	test("1.16", "1.16")     // no patch installed, published on go.dev, as is.
+	test("1.21", "1.21.2")   // no patch installed, the latest remote patch.
+	test("1.22", "1.22")     // no stable patches, as is.
+	test("1.20.3", "1.20.3") // not a minor release, as is.
+	test("1.21rc2", "1.21rc2")
+	assert.Equal[E](t, len(requests), 3) // only for 1.16, 1.21 and 1.22.
+
+	t.Setenv("GOVERSION_NO_NETWORK", "1")
+	test("1.18", "1.18.5") // the same answer offline.
+	test("1.23", "1.23")   // go.dev is unavailable, as is.
+}
+
+func Test_versionFromFile(t *testing.T) {
+	root := t.TempDir()
+	err := os.WriteFile(filepath.Join(root, ".go-version"), []byte("1.18\n"), 0o644)
+	assert.NoErr[F](t, err)
+
+	dir := filepath.Join(root, "a", "b")
+	err = os.MkdirAll(dir, 0o755)
+	assert.NoErr[F](t, err)
+
+	version, err := versionFromFile(dir)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, version, "1.18")
+
+	// go.mod takes precedence over .go-version.
+	err = os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com\n\ngo 1.21.3\n"), 0o644)
+	assert.NoErr[F](t, err)
+
+	version, err = versionFromFile(dir)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, version, "1.21.3")
+}
+
+func Test_versionFromGoMod(t *testing.T) {
+	test := func(data, want string) {
+		t.Helper()
+		name := filepath.Join(t.TempDir(), "go.mod")
+		err := os.WriteFile(name, []byte(data), 0o644)
+		assert.NoErr[F](t, err)
+		version, err := versionFromGoMod(name)
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, version, want)
+	}
+
+	test("module example.com\n", "")
+	test("module example.com\n\ngo 1.21.3\n", "1.21.3")
+	test("module example.com\n\ngo 1.21.3\ntoolchain go1.21.5\n", "1.21.5")
+	test("module example.com\n\ngo 1.21 // a comment\ntoolchain default\n", "1.21")
+
+	name := filepath.Join(t.TempDir(), "go.mod")
+	err := os.WriteFile(name, []byte("module example.com\n\ngo 1.x\n"), 0o644)
+	assert.NoErr[F](t, err)
+	_, err = versionFromGoMod(name)
+	assert.Equal[E](t, errors.As(err, new(malformedError)), true)
+}
+
+func Test_pickVersion(t *testing.T) {
+	local := &local{main: "1.19", current: "1.18", list: []string{"1.19", "1.18", "1.17"}}
+
+	test := func(input, want string) {
+		t.Helper()
+		var buf bytes.Buffer
+		version, err := pickVersion(local, strings.NewReader(input), &buf)
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, version, want)
+		assert.Equal[E](t, "\n"+buf.String(), `
+Select a Go version:
+   1) 1.19 (main)
+*  2) 1.18
+   3) 1.17
+Enter a number [2]: `)
+	}
+
+	test("3\n", "1.17")
+	test("1", "1.19")
+	test("\n", "1.18") // the current version is the default.
+
+	_, err := pickVersion(local, strings.NewReader("4\n"), io.Discard)
+	assert.Equal[E](t, err.Error(), `invalid choice "4"`)
+}
+
+func Test_lockfile(t *testing.T) {
+	t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
+
+	wd, err := os.Getwd()
+	assert.NoErr[F](t, err)
+	defer os.Chdir(wd) //nolint:errcheck // the test is over anyway.
+	dir := t.TempDir()
+	err = os.Chdir(dir)
+	assert.NoErr[F](t, err)
+
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18",
+		files: []dirFile{"go1.17", "go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.17/.unpacked-success"},
+		calls: &steps,
+	}
+	output = io.Discard
+
+	err = lockfile(ctx, nil)
+	assert.NoErr[F](t, err)
+
+	version, err := versionFromLockfile(filepath.Join(dir, "a", "b"))
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, version, "1.18")
+
+	// the locked version is used as is.
+	err = os.WriteFile(lockfileName, []byte("# a comment\n1.17\n"), 0o644)
+	assert.NoErr[F](t, err)
+
+	steps = nil
+	err = use(ctx, []string{"-locked"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, steps[len(steps)-2:], []string{"call: gobin.Symlink(go1.17, .go.tmp)", "call: gobin.Rename(.go.tmp, go)"})
+
+	err = use(ctx, []string{"-locked", "1.17"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+
+	test := func(data string) {
+		t.Helper()
+		_, err := parseLockfile(lockfileName, data)
+		assert.Equal[E](t, err != nil, true)
+	}
+
+	test("")             // no version.
+	test("1.17\n1.18\n") // more than one version.
+	test("latest\n")     // not an exact version.
+	test("tip\n")        // not reproducible.
+}
+
+func Test_install(t *testing.T) {
+	// a single version is used, since the spies are not safe for concurrent use.
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{dir: "gobin", calls: &steps}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18/go1.18.tar.gz"},
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: archiveResponse("1.18", emptySHA256),
+	}
+
+	err := install(ctx, []string{"1.18"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "Downloaded 1.18 SDK in 0s\nInstalled 1.18\n")
+	assert.Equal[E](t, steps, []string{
+		"exec: go version",                               // 1. read main version
+		"call: gobin.Readlink(go)",                       // 2. read current version
+		"call: gobin.ReadDir(.)",                         // 3. read installed versions
+		"http: https://go.dev/dl/?mode=json&include=all", // 4. resolve 1.18 to its latest patch
+		"exec: go install golang.org/dl/go1.18@latest",   // 5. install 1.18
+		"call: sdk.Stat(go1.18/.unpacked-success)",       // 6. check 1.18 SDK
+		"call: sdk.RemoveAll(go1.18)",                    // 7. remove partial 1.18 SDK
+		"exec: go1.18 download",                          // 8. download 1.18 SDK
+		"call: sdk.Stat(go1.18)",                         // 9. measure 1.18 SDK size
+		"http: https://go.dev/dl/?mode=json&include=all", // 10. get 1.18 SDK checksum
+		"call: sdk.Open(go1.18/go1.18.tar.gz)",           // 11. verify 1.18 SDK checksum
+	})
+}
+
+func Test_install_noChecksum(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{dir: "gobin", calls: &steps}
+	sdk = &spyFS{dir: "sdk", calls: &steps}
+	output = io.Discard
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: archiveResponse("1.18", "bad"),
+	}
+
+	err := install(ctx, []string{"-no-checksum", "1.18"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, steps, []string{
+		"exec: go version",                               // 1. read main version
+		"call: gobin.Readlink(go)",                       // 2. read current version
+		"call: gobin.ReadDir(.)",                         // 3. read installed versions
+		"http: https://go.dev/dl/?mode=json&include=all", // 4. resolve 1.18 to its latest patch
+		"exec: go install golang.org/dl/go1.18@latest",   // 5. install 1.18
+		"call: sdk.Stat(go1.18/.unpacked-success)",       // 6. check 1.18 SDK
+		"call: sdk.RemoveAll(go1.18)",                    // 7. remove partial 1.18 SDK
+		"exec: go1.18 download",                          // 8. download 1.18 SDK
+		"call: sdk.Stat(go1.18)",                         // 9. measure 1.18 SDK size
+	})
+}
+
+func Test_install_installed(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		files: []dirFile{"go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18/.unpacked-success"},
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err := install(ctx, []string{"1.18", "1.19"}) // 1.19 is the main version.
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "1.18 is already installed\n1.19 is already installed\n")
+	assert.Equal[E](t, steps, []string{
+		"exec: go version",                         // 1. read main version
+		"call: gobin.Readlink(go)",                 // 2. read current version
+		"call: gobin.ReadDir(.)",                   // 3. read installed versions
+		"call: sdk.Stat(go1.18/.unpacked-success)", // 4. check 1.18 SDK
+	})
+}
+
+func Test_commandOutput(t *testing.T) {
+	var buf bytes.Buffer
+	output = &buf
+
+	t.Run("success", func(t *testing.T) {
+		buf.Reset()
+		err := realCommand(ctx, nil, "go", "env", "GOOS")
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "")
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		buf.Reset()
+		err := realCommand(ctx, nil, "go", "bogus")
+		assert.Equal[E](t, err != nil, true)
+		assert.Equal[E](t, strings.Contains(buf.String(), "go bogus: unknown command"), true)
+	})
+
+	t.Run("live", func(t *testing.T) {
+		buf.Reset()
+		err := realCommand(withLiveOutput(ctx, io.Discard), nil, "go", "bogus")
+		assert.Equal[E](t, err != nil, true)
+		assert.Equal[E](t, buf.String(), "") // streamed to stderr instead.
+	})
+}
+
+func Test_downloadSDK(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+	command = func(_ context.Context, _ []string, name string, args ...string) error {
+		steps = append(steps, "exec: "+name+" "+strings.Join(args, " "))
+		return errors.New("signal: killed")
+	}
+
+	sdk = &spyFS{dir: "sdk", calls: &steps}
+
+	t.Run("timeout", func(t *testing.T) {
+		steps = nil
+		ctx, cancel := context.WithTimeout(ctx, 0)
+		defer cancel()
+
+		err := downloadSDK(ctx, "1.18")
+		assert.IsErr[F](t, err, context.DeadlineExceeded)
+		assert.Equal[E](t, steps, []string{
+			"exec: go1.18 download",       // 1. download 1.18 SDK (times out)
+			"call: sdk.RemoveAll(go1.18)", // 2. remove partial 1.18 SDK
+		})
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		steps = nil
+		ctx, cancel := context.WithCancel(ctx)
+		cancel() // e.g. Ctrl-C.
+
+		err := downloadSDK(ctx, "1.18")
+		assert.IsErr[F](t, err, context.Canceled)
+		assert.Equal[E](t, steps, []string{
+			"exec: go1.18 download",       // 1. download 1.18 SDK (canceled)
+			"call: sdk.RemoveAll(go1.18)", // 2. remove partial 1.18 SDK
+		})
+	})
+}
+
+func Test_reportDownload(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "go1.18", "bin"), 0o755)
+	assert.NoErr[F](t, err)
+	err = os.WriteFile(filepath.Join(dir, "go1.18", "bin", "go"), []byte("12345"), 0o755)
+	assert.NoErr[F](t, err)
+	sdk = dirFS(dir)
+
+	var buf bytes.Buffer
+	output = &buf
+
+	reportDownload("1.18", 37600*time.Millisecond)
+	assert.Equal[E](t, buf.String(), "Downloaded 1.18 SDK (5 B in 38s)\n")
+}
+
+func Test_upgrade(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		files: []dirFile{"go1.18.1"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18.1/.unpacked-success", "go1.18.2/go1.18.2.tar.gz"},
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.19","stable":true},` + strings.Trim(archiveResponse("1.18.2", emptySHA256), "[]") + `,{"version":"go1.18.1","stable":true}]`,
+	}
+
+	err := upgrade(ctx, []string{"-prune", "1.18"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "Downloaded 1.18.2 SDK in 0s\nInstalled 1.18.2\nRemoved 1.18.1\n")
+	assert.Equal[E](t, steps, []string{
+		"exec: go version",                               // 1. read main version
+		"call: gobin.Readlink(go)",                       // 2. read current version
+		"call: gobin.ReadDir(.)",                         // 3. read installed versions
+		"http: https://go.dev/dl/?mode=json&include=all", // 4. get remote versions
+		"exec: go install golang.org/dl/go1.18.2@latest", // 5. install 1.18.2
+		"call: sdk.Stat(go1.18.2/.unpacked-success)",     // 6. check 1.18.2 SDK
+		"call: sdk.RemoveAll(go1.18.2)",                  // 7. remove partial 1.18.2 SDK
+		"exec: go1.18.2 download",                        // 8. download 1.18.2 SDK
+		"call: sdk.Stat(go1.18.2)",                       // 9. measure 1.18.2 SDK size
+		"http: https://go.dev/dl/?mode=json&include=all", // 10. get 1.18.2 SDK checksum
+		"call: sdk.Open(go1.18.2/go1.18.2.tar.gz)",       // 11. verify 1.18.2 SDK checksum
+		"exec: go version",                               // 12. read main version (remove)
+		"call: gobin.Readlink(go)",                       // 13. read current version (remove)
+		"call: gobin.ReadDir(.)",                         // 14. read installed versions (remove)
+		"call: gobin.Remove(go1.18.1)",                   // 15. remove 1.18.1 binary
+		"call: sdk.RemoveAll(go1.18.1)",                  // 16. remove 1.18.1 SDK
+	})
+}
+
+func Test_list(t *testing.T) {
+	t.Run("list local versions", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.17", "go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success"}, // 1.17 SDK is missing.
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := list(ctx, nil)
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, "\n"+buf.String(), `
+  1.19       (main)
+* 1.18      
+  1.17       (missing SDK)
+`)
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",                         // 1. read main version
+			"call: gobin.Readlink(go)",                 // 2. read current version
+			"call: gobin.ReadDir(.)",                   // 3. read installed versions
+			"call: sdk.ReadDir(.)",                     // 4. read downloaded SDKs
+			"call: sdk.Stat(go1.18/.unpacked-success)", // 5. check 1.18 SDK
+			"call: sdk.Stat(go1.17/.unpacked-success)", // 6. check 1.17 SDK
+		})
+
+		t.Run("colors", func(t *testing.T) {
+			// not a terminal.
+			buf.Reset()
+			err := list(ctx, nil)
+			assert.NoErr[F](t, err)
+			assert.Equal[E](t, strings.Contains(buf.String(), "\033["), false)
+
+			buf.Reset()
+			t.Setenv("CLICOLOR_FORCE", "1")
+			err = list(ctx, nil)
+			assert.NoErr[F](t, err)
+			assert.Equal[E](t, "\n"+buf.String(), "\n"+
+				"  1.19       \033[2m(main)\033[0m\n"+
+				"\033[32m*\033[0m \033[32m1.18      \033[0m\n"+
+				"  1.17       \033[33m(missing SDK)\033[0m\n")
+
+			buf.Reset()
+			t.Setenv("NO_COLOR", "1")
+			err = list(ctx, nil)
+			assert.NoErr[F](t, err)
+			assert.Equal[E](t, strings.Contains(buf.String(), "\033["), false)
+		})
+
+		t.Run("count", func(t *testing.T) {
+			var out bytes.Buffer
+			stdout = &out
+
+			test := func(args []string, want string) {
+				t.Helper()
+				out.Reset()
+				err := list(ctx, args)
+				assert.NoErr[F](t, err)
+				assert.Equal[E](t, out.String(), want)
+			}
+
+			test([]string{"-count"}, "3\n")
+			test([]string{"-count", "-no-main"}, "2\n")
+			test([]string{"-count", "-only=1.17"}, "1\n")
+
+			httpClient = &httpSpy{
+				requests: &steps,
+				response: `[{"version":"go1.20"},{"version":"go1.19"},{"version":"go1.18"}]`,
+			}
+			test([]string{"-count", "-all"}, "4\n")              // tip, 1.20, 1.19 and 1.18 (1.17 is installed only).
+			test([]string{"-count", "-all", "-only=1.2"}, "1\n") // 1.20.
+
+			err := list(ctx, []string{"-count", "-json"})
+			assert.Equal[E](t, errors.As(err, new(usageError)), true)
+		})
+	})
+
+	t.Run("list remote versions", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success"},
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"1.19"},{"version":"1.18"},{"version":"1.17"}]`,
+		}
+
+		err := list(ctx, []string{"-all"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, "\n"+buf.String(), `
+  tip       
+  1.19       (main)
+* 1.18       (installed)
+  1.17      
+`)
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",                               // 1. read main version
+			"call: gobin.Readlink(go)",                       // 2. read current version
+			"call: gobin.ReadDir(.)",                         // 3. read installed versions
+			"call: sdk.ReadDir(.)",                           // 4. read downloaded SDKs
+			"http: https://go.dev/dl/?mode=json&include=all", // 5. get remote versions
+			"call: sdk.Stat(go1.18/.unpacked-success)",       // 6. check 1.18 SDK
+		})
+	})
+
+	t.Run("list versions with missing binaries", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18", "go1.18/.unpacked-success", "go1.17", "go1.17/.unpacked-success"},
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := list(ctx, nil)
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, "\n"+buf.String(), `
+  1.19       (main)
+* 1.18      
+  1.17       (binary missing)
+`)
+
+		var out bytes.Buffer
+		stdout = &out
+
+		err = list(ctx, []string{"-count"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, out.String(), "2\n") // 1.19 and 1.18, the SDK alone is not counted.
+	})
+
+	t.Run("list remote versions only", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		var buf bytes.Buffer
+		output = &buf
+
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"1.19"},{"version":"1.18"}]`,
+		}
+
+		err := list(ctx, []string{"-remote-only"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, "\n"+buf.String(), `
+  tip       
+  1.19      
+  1.18      
+`)
+		assert.Equal[E](t, steps, []string{
+			"http: https://go.dev/dl/?mode=json&include=all", // 1. get remote versions
+		})
+	})
+
+	t.Run("list latest patches only", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.20.1",
+			files: []dirFile{"go1.20.1", "go1.20.7", "go1.21.2"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.20.1/.unpacked-success", "go1.20.7/.unpacked-success", "go1.21.2/.unpacked-success"},
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		stdout = &buf
+
+		err := list(ctx, []string{"-latest-only", "-porcelain"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, "\n"+buf.String(), `
+1.21.2	installed,sdk
+1.20.7	installed,sdk
+1.19	main,installed,sdk
+`)
+
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"go1.21.3"},{"version":"go1.21.2"},{"version":"go1.21rc1"},{"version":"go1.20.8"}]`,
+		}
+
+		buf.Reset()
+		err = list(ctx, []string{"-latest-only", "-all", "-sort=asc", "-porcelain"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, "\n"+buf.String(), `
+1.19	main,installed,sdk
+1.20.8	-
+1.21.3	-
+tip	-
+`)
+
+		err = list(ctx, []string{"-latest-only", "-all-patches", "-only=1.20"})
+		assert.Equal[E](t, errors.As(err, new(usageError)), true)
+	})
+}
+
+func Test_listOfflineFallback(t *testing.T) {
+	defer func(dir string) { cacheDir = dir }(cacheDir)
+	cacheDir = t.TempDir()
+
+	t.Setenv("GOVERSION_NO_NETWORK", "1")
+
+	writeCache(remoteURL(), []byte(`[{"version":"go1.19"},{"version":"go1.18"}]`))
+	cachedAt := time.Date(2022, 12, 20, 12, 0, 0, 0, time.Local)
+	err := os.Chtimes(cachePath(remoteURL()), cachedAt, cachedAt)
+	assert.NoErr[F](t, err)
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err = list(ctx, []string{"-remote-only"})
+	assert.IsErr[F](t, err, errNoNetwork)
+
+	err = list(ctx, []string{"-remote-only", "-offline-fallback"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, "\n"+buf.String(), `
+Warning: network access is disabled by $GOVERSION_NO_NETWORK, using the list cached at 2022-12-20 12:00:00
+  tip       
+  1.19      
+  1.18      
+`)
+}
+
+func Test_listFormat(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18",
+		files: []dirFile{"go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18/.unpacked-success"},
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	stdout = &buf
+
+	err := list(ctx, []string{"-format", "{{.Version}}{{if .Current}} *{{end}}"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "1.19\n1.18 *\n")
+
+	err = list(ctx, []string{"-format", "{{.Version"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+}
+
+func Test_listStable(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{dir: "gobin", calls: &steps}
+	sdk = &spyFS{dir: "sdk", calls: &steps}
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.20rc1"},{"version":"go1.19","stable":true},{"version":"go1.19beta1"}]`,
+	}
+
+	test := func(args []string, want string) {
+		t.Helper()
+		var buf bytes.Buffer
+		stdout = &buf
+		err := list(ctx, append([]string{"-remote-only", "-format", "{{.Version}}"}, args...))
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), want)
+	}
+
+	test(nil, "tip\n1.20rc1\n1.19\n1.19beta1\n")
+	test([]string{"-stable"}, "1.19\n")
+	test([]string{"-prerelease"}, "1.20rc1\n1.19beta1\n")
+
+	err := list(ctx, []string{"-stable"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+
+	err = list(ctx, []string{"-prerelease"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+
+	err = list(ctx, []string{"-all", "-stable", "-prerelease"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+}
+
+func Test_listSort(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		files: []dirFile{"go1.17", "go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.17/.unpacked-success", "go1.18/.unpacked-success"},
+		calls: &steps,
+	}
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.20"},{"version":"go1.18"},{"version":"go1.17"}]`,
+	}
+
+	test := func(args []string, want string) {
+		t.Helper()
+		var buf bytes.Buffer
+		stdout = &buf
+		err := list(ctx, append([]string{"-format", "{{.Version}}"}, args...))
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), want)
+	}
+
+	test(nil, "1.19\n1.18\n1.17\n")
+	test([]string{"-sort=desc"}, "1.19\n1.18\n1.17\n")
+	test([]string{"-sort=asc"}, "1.17\n1.18\n1.19\n")
+	test([]string{"-sort=asc", "-all"}, "1.17\n1.18\n1.19\n1.20\ntip\n")
+
+	err := list(ctx, []string{"-sort=newest"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+}
+
+func Test_listPorcelain(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18",
+		files: []dirFile{"go1.17", "go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18/.unpacked-success"},
+		calls: &steps,
+	}
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.20"},{"version":"go1.18"}]`,
+	}
+
+	var buf bytes.Buffer
+	stdout = &buf
+
+	err := list(ctx, []string{"-all", "-porcelain"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, "\n"+buf.String(), `
+tip	-
+1.20	-
+1.19	main,installed,sdk
+1.18	current,installed,sdk
+1.17	installed
+`)
+
+	err = list(ctx, []string{"-porcelain", "-json"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+}
+
+func Test_listAllPatches(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.20.1",
+		files: []dirFile{"go1.2", "go1.20.1", "go1.20.2"}, // 1.20.2 has been removed from go.dev.
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.20.1/.unpacked-success"},
+		calls: &steps,
+	}
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.21"},{"version":"go1.20.3"},{"version":"go1.20.1"},{"version":"go1.20"},{"version":"go1.20rc1"}]`,
+	}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err := list(ctx, []string{"-all-patches", "-only=1.20"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, "\n"+buf.String(), `
+  1.20.3    
+  1.20.2     (missing SDK)
+* 1.20.1     (installed)
+  1.20      
+`)
+
+	err = list(ctx, []string{"-all-patches", "-only=1.20.1"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+
+	err = list(ctx, []string{"-all-patches", "-remote-only", "-only=1.20"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+}
+
+func Test_listGroup(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18.1",
+		files: []dirFile{"go1.18.1"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18.1/.unpacked-success"},
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.19"},{"version":"go1.18.2"},{"version":"go1.18.1"},{"version":"go1.18"},{"version":"go1.17"}]`,
+	}
+
+	err := list(ctx, []string{"-all", "-group", "-only=>=1.18"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, "\n"+buf.String(), `
+tip
+    tip       
+1.19
+    1.19       (main)
+1.18
+    1.18.2    
+  * 1.18.1     (installed)
+    1.18      
+`)
+}
+
+func Test_listOutdated(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18.1",
+		files: []dirFile{"go1.17.13", "go1.18.1"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.17.13/.unpacked-success", "go1.18.1/.unpacked-success"},
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.20rc1","stable":false},{"version":"go1.19","stable":true},` +
+			`{"version":"go1.18.2","stable":true},{"version":"go1.18.1","stable":true},{"version":"go1.17.13","stable":true}]`,
+	}
+
+	err := list(ctx, []string{"-outdated"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, "\n"+buf.String(), `
+* 1.18.1     -> 1.18.2 available
+`)
+}
+
+func Test_listJSON(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18",
+		files: []dirFile{"go1.17", "go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18/.unpacked-success"}, // 1.17 SDK is missing.
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	stdout = &buf
+
+	err := list(ctx, []string{"-json", "-only=1.1"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), `[`+
+		`{"version":"1.19","current":false,"main":true,"installed":true,"sdk":true},`+
+		`{"version":"1.18","current":true,"main":false,"installed":true,"sdk":true},`+
+		`{"version":"1.17","current":false,"main":false,"installed":true,"sdk":false}`+
+		`]`+"\n")
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.19","stable":true,"files":[` +
+			`{"filename":"go1.19.src.tar.gz","os":"","arch":"","kind":"source","sha256":"abc","size":42}]},` +
+			`{"version":"go1.19rc1","stable":false,"files":[]}]`,
+	}
+
+	buf.Reset()
+	err = list(ctx, []string{"-json", "-remote-only", "-only=1.19"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), `[`+
+		`{"version":"1.19","current":false,"main":false,"installed":false,"sdk":false,"kind":"stable","files":[`+
+		`{"filename":"go1.19.src.tar.gz","os":"","arch":"","kind":"source","sha256":"abc","size":42}]},`+
+		`{"version":"1.19rc1","current":false,"main":false,"installed":false,"sdk":false,"kind":"rc"}`+
+		`]`+"\n")
+
+	// the local state is merged into the remote list, including the versions that are not on go.dev.
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.19","stable":true,"files":[]},{"version":"go1.18","stable":true,"files":[]}]`,
+	}
+
+	buf.Reset()
+	err = list(ctx, []string{"-json", "-all", "-only=1.1"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), `[`+
+		`{"version":"1.19","current":false,"main":true,"installed":true,"sdk":true,"kind":"stable"},`+
+		`{"version":"1.18","current":true,"main":false,"installed":true,"sdk":true,"kind":"stable"},`+
+		`{"version":"1.17","current":false,"main":false,"installed":true,"sdk":false}`+
+		`]`+"\n")
+}
+
+func Test_formatSize(t *testing.T) {
+	test := func(size int64, want string) {
+		t.Helper()
+		assert.Equal[E](t, formatSize(size), want)
+	}
+
+	test(0, "0 B")
+	test(1023, "1023 B")
+	test(1024, "1.0 KiB")
+	test(1536, "1.5 KiB")
+	test(1<<20, "1.0 MiB")
+	test(1288490189, "1.2 GiB")
+}
+
+func Test_remoteVersions(t *testing.T) {
+	httpRetryDelay = 0
+
+	t.Run("retry on 5xx", func(t *testing.T) {
+		var steps []string
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"go1.19","stable":true}]`,
+			statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+		}
+
+		remote, err := remoteVersions(ctx, false)
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, remote.list, []string{"tip", "1.19"})
+		assert.Equal[E](t, len(steps), 3)
+	})
+
+	t.Run("no retry on 4xx", func(t *testing.T) {
+		var steps []string
+		httpClient = &httpSpy{
+			requests: &steps,
+			statuses: []int{http.StatusNotFound},
+		}
+
+		_, err := remoteVersions(ctx, false)
+		assert.Equal[F](t, err.Error(), "unexpected response from go.dev (status 404)")
+		assert.Equal[E](t, len(steps), 1)
+	})
+
+	t.Run("no retry for best-effort checks", func(t *testing.T) {
+		var steps []string
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"go1.19","stable":true}]`,
+			statuses: []int{http.StatusBadGateway},
+		}
+
+		_, err := quickRemoteVersions(ctx)
+		assert.Equal[F](t, err.Error(), "unexpected response from go.dev (status 502)")
+		assert.Equal[E](t, len(steps), 1)
+	})
+
+	t.Run("custom mirror", func(t *testing.T) {
+		var steps []string
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[]`,
+		}
+
+		// the trailing slash is optional.
+		for _, u := range []string{"https://example.com/golang/", "https://example.com/golang"} {
+			t.Setenv("GOVERSION_DL_URL", u)
+			_, err := remoteVersions(ctx, false)
+			assert.NoErr[F](t, err)
+		}
+		assert.Equal[E](t, steps, []string{
+			"http: https://example.com/golang/?mode=json&include=all",
+			"http: https://example.com/golang/?mode=json&include=all",
+		})
+	})
+
+	t.Run("cached response", func(t *testing.T) {
+		cacheDir = t.TempDir()
+		defer func() { cacheDir = "" }()
+
+		var steps []string
+		httpClient = &httpSpy{
+			requests: &steps,
+			response: `[{"version":"go1.19","stable":true}]`,
+		}
+
+		for i := 0; i < 2; i++ {
+			remote, err := remoteVersions(ctx, true)
+			assert.NoErr[F](t, err)
+			assert.Equal[E](t, remote.list, []string{"tip", "1.19"})
+		}
+		assert.Equal[E](t, len(steps), 1) // the second call used the cache.
+
+		_, err := remoteVersions(ctx, false)
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, len(steps), 2)
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		t.Setenv("GOVERSION_HTTP_RETRIES", "1")
+
+		var steps []string
+		httpClient = &httpSpy{
+			requests: &steps,
+			statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError},
+		}
+
+		_, err := remoteVersions(ctx, false)
+		assert.Equal[F](t, err.Error(), "unexpected response from go.dev (status 500)")
+		assert.Equal[E](t, len(steps), 2)
+	})
+
+	t.Run("HTML error page", func(t *testing.T) {
+		var steps []string
+		httpClient = &httpSpy{
+			requests:    &steps,
+			response:    `<html><body>Service Unavailable</body></html>`,
+			contentType: "text/html; charset=utf-8",
+		}
+
+		_, err := remoteVersions(ctx, false)
+		assert.Equal[F](t, err.Error(), "unexpected response from go.dev (content type text/html; charset=utf-8)")
+	})
+
+	t.Run("static mirror", func(t *testing.T) {
+		for _, ct := range []string{"text/plain; charset=utf-8", "application/octet-stream"} {
+			var steps []string
+			httpClient = &httpSpy{
+				requests:    &steps,
+				response:    `[{"version":"go1.19","stable":true}]`,
+				contentType: ct,
+			}
+
+			remote, err := remoteVersions(ctx, false)
+			assert.NoErr[F](t, err)
+			assert.Equal[E](t, remote.list, []string{"tip", "1.19"})
+		}
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		cacheDir = t.TempDir()
+		defer func() { cacheDir = "" }()
+
+		var steps []string
+		httpClient = &httpSpy{
+			requests:    &steps,
+			response:    `{"versions":[]}`,
+			contentType: "application/json",
+		}
+
+		_, err := remoteVersions(ctx, true)
+		assert.Equal[F](t, errors.As(err, new(*json.UnmarshalTypeError)), true)
+
+		data, err := readCache(remoteURL())
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, data, []byte(nil)) // the invalid response is not cached.
+	})
+}
+
+func Test_mainVersionCache(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	defer func(dir string) { cacheDir = dir }(cacheDir)
+	cacheDir = t.TempDir()
+
+	// the main go binary is never run, it's only looked for in $PATH.
+	bin := t.TempDir()
+	err := os.WriteFile(filepath.Join(bin, exe("go")), nil, 0o755)
+	assert.NoErr[F](t, err)
+	t.Setenv("PATH", bin)
+	t.Setenv("GOBIN", "")
+
+	dir := t.TempDir()
+	gobin = dirFS(dir)
+
+	test := func(wantRun bool) {
+		t.Helper()
+		steps = nil
+		local, err := localVersions(ctx)
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, local.main, mainVersion)
+		assert.Equal[E](t, len(steps) == 1, wantRun)
+	}
+
+	test(true)
+	test(false) // cached.
+
+	// modifying $GOBIN (e.g. switching versions) doesn't invalidate the cache.
+	err = os.Chtimes(dir, time.Now(), time.Now().Add(-time.Hour))
+	assert.NoErr[F](t, err)
+	test(false)
+
+	// upgrading Go does.
+	err = os.Chtimes(filepath.Join(bin, exe("go")), time.Now(), time.Now().Add(-time.Hour))
+	assert.NoErr[F](t, err)
+	test(true)
+	test(false)
+
+	noCache = true
+	defer func() { noCache = false }()
+	test(true)
+}
+
+func Test_envWithoutGOBIN(t *testing.T) {
+	path := func(dirs ...string) string {
+		return strings.Join(dirs, string(os.PathListSeparator))
+	}
+
+	env := envWithoutGOBIN([]string{
+		"HOME=/home/gopher",
+		"GOBIN=/home/gopher/go/bin",
+		"PATH=" + path("/home/gopher/go/bin", "/usr/local/go/bin", "/usr/bin"),
+	})
+	assert.Equal[E](t, env, []string{
+		"HOME=/home/gopher",
+		"GOBIN=/home/gopher/go/bin",
+		"PATH=" + path("/usr/local/go/bin", "/usr/bin"),
+	})
+
+	// $GOBIN and its $PATH representation may differ in cleanliness.
+	env = envWithoutGOBIN([]string{
+		"GOBIN=/home/gopher/go/bin/",
+		"PATH=" + path("/home/gopher/go/./bin", "/usr/local/go/bin", "/usr/bin"),
+	})
+	assert.Equal[E](t, env, []string{
+		"GOBIN=/home/gopher/go/bin/",
+		"PATH=" + path("/usr/local/go/bin", "/usr/bin"),
+	})
+}
+
+func Test_goInstallEnv(t *testing.T) {
+	t.Setenv("GOPROXY", "https://proxy.corp.example")
+
+	t.Setenv("GOVERSION_GOPROXY", "")
+	assert.Equal[E](t, goInstallEnv(), nil)
+
+	t.Setenv("GOVERSION_GOPROXY", "https://proxy.golang.org")
+	assert.Equal[E](t, getEnv(goInstallEnv(), "GOPROXY"), "https://proxy.golang.org")
+}
+
+func Test_concurrency(t *testing.T) {
+	test := func(value string, want int) {
+		t.Helper()
+		t.Setenv("GOVERSION_CONCURRENCY", value)
+		n, err := concurrency()
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, n, want)
+	}
+
+	want := maxConcurrency
+	if runtime.NumCPU() < want {
+		want = runtime.NumCPU()
+	}
+	test("", want)
+	test("1", 1)
+	test("16", 16)
+
+	for _, value := range []string{"0", "-1", "many"} {
+		t.Setenv("GOVERSION_CONCURRENCY", value)
+		_, err := concurrency()
+		assert.Equal[E](t, err.Error(), fmt.Sprintf("invalid $GOVERSION_CONCURRENCY value %q", value))
+	}
+}
+
+func Test_httpTimeout(t *testing.T) {
+	test := func(value string, want time.Duration) {
+		t.Helper()
+		t.Setenv("GOVERSION_HTTP_TIMEOUT", value)
+		assert.Equal[E](t, httpTimeout(), want)
+	}
+
+	test("", time.Minute)
+	test("5m", 5*time.Minute)
+	test("10", time.Minute)
+	test("-1s", time.Minute)
+}
+
+func Test_remove(t *testing.T) {
+	t.Run("remove existing version", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success"},
+			calls: &steps,
+		}
+		output = io.Discard
+
+		err := remove(ctx, []string{"1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",            // 1. read main version
+			"call: gobin.Readlink(go)",    // 2. read current version
+			"call: gobin.ReadDir(.)",      // 3. read installed versions
+			"call: gobin.Remove(go)",      // 4. remove symlink (switch to main)
+			"call: gobin.Remove(go1.18)",  // 4. remove 1.18 binary
+			"call: sdk.RemoveAll(go1.18)", // 5. remove 1.18 SDK
+		})
+	})
+
+	t.Run("remove current version with latest fallback", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.17", "go1.18", "go1.20"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.17/.unpacked-success", "go1.18/.unpacked-success"}, // 1.20 SDK is missing.
+			calls: &steps,
+		}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := remove(ctx, []string{"-fallback=latest", "1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.17\nRemoved 1.18\n")
+		assert.Equal[E](t, steps[3:], []string{
+			"call: sdk.Stat(go1.20/.unpacked-success)", // 1. check 1.20 SDK
+			"call: sdk.Stat(go1.17/.unpacked-success)", // 2. check 1.17 SDK
+			"call: gobin.Symlink(go1.17, .go.tmp)",     // 3. create new symlink
+			"call: gobin.Rename(.go.tmp, go)",          // 4. switch to 1.17
+			"call: gobin.Remove(go1.18)",               // 5. remove 1.18 binary
+			"call: sdk.RemoveAll(go1.18)",              // 6. remove 1.18 SDK
+		})
+
+		// only main is left, so there is nothing else to switch to.
+		gobin = &spyFS{dir: "gobin", link: "/path/to/go1.18", files: []dirFile{"go1.18"}, calls: &steps}
+		buf.Reset()
+		err = remove(ctx, []string{"-fallback=latest", "1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.19 (main)\nRemoved 1.18\n")
+
+		err = remove(ctx, []string{"-fallback=oldest", "1.18"})
+		assert.Equal[E](t, errors.As(err, new(usageError)), true)
+	})
+
+	t.Run("remove versions matching glob", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18.2",
+			files: []dirFile{"go1.17", "go1.18", "go1.18.1", "go1.18.2"},
+			calls: &steps,
+		}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := remove(ctx, []string{"1.18.*"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.19 (main)\nRemoved 1.18.2\nRemoved 1.18.1\n")
+
+		err = remove(ctx, []string{"1.2?"})
+		assert.Equal[E](t, errors.As(err, new(notFoundError)), true)
+
+		err = remove(ctx, []string{"1.[18"})
+		assert.Equal[E](t, errors.As(err, new(usageError)), true)
+	})
+
+	t.Run("remove tip", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{dir: "gobin", files: []dirFile{"gotip"}, calls: &steps}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := remove(ctx, []string{"tip"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Removed tip\n")
+		assert.Equal[E](t, steps[len(steps)-2:], []string{
+			"call: gobin.Remove(gotip)",  // 1. remove tip binary
+			"call: sdk.RemoveAll(gotip)", // 2. remove tip SDK
+		})
+	})
+
+	t.Run("remove go-prefixed version", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+		output = io.Discard
+
+		err := remove(ctx, []string{"go1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, steps[len(steps)-2:], []string{
+			"call: gobin.Remove(go1.18)",  // 1. remove 1.18 binary
+			"call: sdk.RemoveAll(go1.18)", // 2. remove 1.18 SDK
+		})
+	})
+
+	t.Run("remove binary only", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := remove(ctx, []string{"-keep-sdk", "1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Removed 1.18 (the SDK is kept)\n")
+		assert.Equal[E](t, steps[len(steps)-1], "call: gobin.Remove(go1.18)")
+	})
+
+	t.Run("remove all versions except specified", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.17", "go1.18", "go1.20"},
+			calls: &steps,
+		}
+		sdk = &spyFS{dir: "sdk", calls: &steps} // the SDKs are missing, so nothing is reclaimed.
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := remove(ctx, []string{"-all-except", "1.20"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, "\n"+buf.String(), `
+Switched to 1.19 (main)
+Removed 1.18
+Removed 1.17
+Removed 2 version(s), reclaimed 0 B
+`)
+	})
+
+	t.Run("remove versions by prefix", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18.1",
+			files: []dirFile{"go1.17", "go1.18", "go1.18.1"},
+			calls: &steps,
+		}
+		sdk = &spyFS{dir: "sdk", calls: &steps}
+
+		var buf bytes.Buffer
+		output = &buf
+
+		err := remove(ctx, []string{"-only=1.18"})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.19 (main)\nRemoved 1.18.1\nRemoved 1.18\n")
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",              // 1. read main version
+			"call: gobin.Readlink(go)",      // 2. read current version
+			"call: gobin.ReadDir(.)",        // 3. read installed versions
+			"call: gobin.Remove(go)",        // 4. remove symlink (switch to main)
+			"call: gobin.Remove(go1.18.1)",  // 5. remove 1.18.1 binary
+			"call: sdk.RemoveAll(go1.18.1)", // 6. remove 1.18.1 SDK
+			"call: gobin.Remove(go1.18)",    // 7. remove 1.18 binary
+			"call: sdk.RemoveAll(go1.18)",   // 8. remove 1.18 SDK
+		})
+	})
+
+	t.Run("remove non-existing version", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			link:  "/path/to/go1.18",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success"},
+			calls: &steps,
+		}
+		output = io.Discard
+
+		err := remove(ctx, []string{"1.17"})
+		assert.Equal[F](t, err.Error(), "1.17 is not installed")
+		assert.Equal[E](t, steps, []string{
+			"exec: go version",         // 1. read main version
+			"call: gobin.Readlink(go)", // 2. read current version
+			"call: gobin.ReadDir(.)",   // 3. read installed versions
+		})
+	})
+}
+
+func Test_gc(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		files: []dirFile{"go1.18"},
+		calls: &steps,
+	}
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"go1.17/bin/go":            "12345", // the binary is missing.
+		"go1.18/.unpacked-success": "",
+		"other/file":               "", // not an SDK.
+	} {
+		err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)
+		assert.NoErr[F](t, err)
+		err = os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644)
+		assert.NoErr[F](t, err)
+	}
+	sdk = dirFS(dir)
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err := gc(ctx, []string{"-dry-run"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "[dry-run] Removed 1.17 SDK (5 B)\n[dry-run] Removed 1 orphaned SDK(s), reclaimed 5 B\n")
+	_, err = os.Stat(filepath.Join(dir, "go1.17"))
+	assert.NoErr[F](t, err)
+
+	buf.Reset()
+	err = gc(ctx, nil)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "Removed 1.17 SDK (5 B)\nRemoved 1 orphaned SDK(s), reclaimed 5 B\n")
+	_, err = os.Stat(filepath.Join(dir, "go1.17"))
+	assert.IsErr[F](t, err, fs.ErrNotExist)
+
+	buf.Reset()
+	err = gc(ctx, nil)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "Nothing to clean up\n")
+}
+
+func Test_downloadCrossSDK(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	defer func(dir string) { cacheDir = dir }(cacheDir)
+	cacheDir = t.TempDir()
+
+	const archive = "linux/arm64 SDK"
+	sum := sha256.Sum256([]byte(archive))
+
+	// the list of versions is cached, so the only request is for the archive.
+	writeCache(remoteURL(), []byte(`[{"version":"go1.22.0","stable":true,"files":[`+
+		`{"filename":"go1.22.0.linux-arm64.tar.gz","os":"linux","arch":"arm64","kind":"archive","sha256":"`+hex.EncodeToString(sum[:])+`"},`+
+		`{"filename":"go1.22.0.linux-armv6l.tar.gz","os":"linux","arch":"armv6l","kind":"archive","sha256":"`+hex.EncodeToString(sum[:])+`"},`+
+		`{"filename":"go1.22.0.linux-amd64.tar.gz","os":"linux","arch":"amd64","kind":"archive","sha256":"bad"}]}]`))
+
+	httpClient = &httpSpy{requests: &steps, response: archive}
+
+	gobin = &spyFS{dir: "gobin", calls: &steps}
+	dir := t.TempDir()
+	sdk = dirFS(dir)
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err := use(ctx, []string{"-os=linux", "-arch=arm64", "1.22.0"})
+	assert.NoErr[F](t, err)
+	path := filepath.Join(dir, "go1.22.0.linux-arm64.tar.gz")
+	assert.Equal[E](t, buf.String(), "Downloaded 1.22.0 SDK for linux/arm64 to "+path+"\n")
+	assert.Equal[E](t, steps[len(steps)-1], "http: https://go.dev/dl/go1.22.0.linux-arm64.tar.gz")
+
+	data, err := os.ReadFile(path)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, string(data), archive)
+
+	_, err = downloadCrossSDK(ctx, "1.22.0", "linux", "amd64")
+	assert.Equal[E](t, err.Error(), "1.22.0 SDK for linux/amd64 checksum mismatch: want bad, got "+hex.EncodeToString(sum[:]))
+	entries, err := os.ReadDir(dir)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, len(entries), 1) // the partial download has been removed.
+
+	// go.dev publishes the arm archives as armv6l.
+	path, err = downloadCrossSDK(ctx, "1.22.0", "linux", "arm")
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, path, filepath.Join(dir, "go1.22.0.linux-armv6l.tar.gz"))
+
+	_, err = downloadCrossSDK(ctx, "1.22.0", "windows", "arm64")
+	assert.Equal[E](t, errors.As(err, new(notFoundError)), true)
+
+	err = use(ctx, []string{"-os=linux", "-print-path", "1.22.0"})
+	assert.Equal[E](t, errors.As(err, new(usageError)), true)
+}
+
+func Test_replaceLink(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"go1.17", "go1.18"} {
+		err := os.WriteFile(filepath.Join(dir, exe(name)), nil, 0o755)
+		assert.NoErr[F](t, err)
+	}
+	gobin = dirFS(dir)
+
+	// left after goversion has been killed in the middle of the switch.
+	err := gobin.Symlink("go1.17", ".go.tmp")
+	assert.NoErr[F](t, err)
+
+	for _, version := range []string{"go1.18", "go1.17"} {
+		err = replaceLink(version)
+		assert.NoErr[F](t, err)
+
+		target, err := gobin.Readlink("go")
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, filepath.Base(target), version)
+
+		_, err = gobin.Readlink(".go.tmp")
+		assert.IsErr[E](t, err, fs.ErrNotExist)
+	}
+}
+
+func Test_execute(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	var path string
+	record := command
+	command = func(ctx context.Context, env []string, name string, args ...string) error {
+		path = getEnv(env, "PATH")
+		return record(ctx, env, name, args...)
+	}
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		files: []dirFile{"go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18/.unpacked-success"},
+		calls: &steps,
+	}
+
+	err := execute(ctx, []string{"1.18", "--", "go", "build", "./..."})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, strings.HasPrefix(path, "sdk/go1.18/bin"+string(os.PathListSeparator)), true)
+	assert.Equal[E](t, steps, []string{
+		"exec: go version",                         // 1. read main version
+		"call: gobin.Readlink(go)",                 // 2. read current version
+		"call: gobin.ReadDir(.)",                   // 3. read installed versions
+		"call: sdk.Stat(go1.18/.unpacked-success)", // 4. check 1.18 SDK
+		"exec: go build ./...",                     // 5. run the command
+	})
+
+	t.Run("minor release", func(t *testing.T) {
+		gobin = &spyFS{dir: "gobin", files: []dirFile{"go1.21.3"}, calls: &steps}
+		sdk = &spyFS{dir: "sdk", files: []dirFile{"go1.21.3/.unpacked-success"}, calls: &steps}
+		httpClient = &httpSpy{requests: &steps, response: `[{"version":"go1.21.3","stable":true}]`}
+
+		err := execute(ctx, []string{"1.21", "--", "go", "build", "./..."})
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, strings.HasPrefix(path, "sdk/go1.21.3/bin"+string(os.PathListSeparator)), true)
+	})
+}
+
+func Test_current(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18",
+		files: []dirFile{"go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{dir: "sdk", calls: &steps}
+
+	var buf bytes.Buffer
+	stdout = &buf
+
+	err := current(ctx, nil)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "1.18\n")
+	assert.Equal[E](t, steps, []string{
+		"exec: go version",         // 1. read main version
+		"call: gobin.Readlink(go)", // 2. read current version
+		"call: gobin.ReadDir(.)",   // 3. read installed versions
+	})
+
+	buf.Reset()
+	err = current(ctx, []string{"-json"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), `{"version":"1.18","main":false}`+"\n")
+
+	t.Run("foreign symlink", func(t *testing.T) {
+		gobin = &spyFS{dir: "gobin", link: "/usr/bin/python3", files: []dirFile{"go1.18"}, calls: &steps}
+		output = io.Discard
+
+		for _, args := range [][]string{nil, {"-json"}} {
+			buf.Reset()
+			err := current(ctx, args)
+			assert.Equal[E](t, err != nil, true)
+			assert.Equal[E](t, buf.String(), "")
+		}
+	})
+}
+
+func Test_which(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		files: []dirFile{"go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18/.unpacked-success"},
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	stdout = &buf
+
+	err := which(ctx, []string{"1.18"})
+	assert.NoErr[F](t, err)
+	err = which(ctx, []string{"-sdk", "1.18"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "gobin/go1.18\nsdk/go1.18/bin/go\n")
+
+	err = which(ctx, []string{"1.17"})
+	assert.Equal[F](t, err.Error(), "1.17 is not installed")
+
+	// the go prefix (e.g. copied from the `go version` output) is trimmed.
+	buf.Reset()
+	err = which(ctx, []string{"go1.18"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "gobin/go1.18\n")
+
+	buf.Reset()
+	err = which(ctx, []string{"-json", "1.18"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), `{"version":"1.18","path":"gobin/go1.18"}`+"\n")
+}
+
+func Test_verify(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		files: []dirFile{"go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18/.unpacked-success", "go1.18/bin/go"},
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err := verify(ctx, []string{"1.18"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "1.18 SDK is OK\n")
+	assert.Equal[E](t, steps, []string{
+		"exec: go version",                         // 1. read main version
+		"call: gobin.Readlink(go)",                 // 2. read current version
+		"call: gobin.ReadDir(.)",                   // 3. read installed versions
+		"call: sdk.Stat(go1.18/.unpacked-success)", // 4. check 1.18 SDK
+		"call: sdk.Stat(go1.18/bin/go)",            // 5. check 1.18 go binary
+		"exec: go1.18 version",                     // 6. check 1.18 reported version
+	})
+
+	t.Run("platform mismatch", func(t *testing.T) {
+		commandOutput = func(_ context.Context, _ []string, name string, _ ...string) (string, error) {
+			if name == "go" {
+				return "go version go" + mainVersion + " " + hostPlatform(), nil
+			}
+			return "go version go1.18 plan9/386", nil
+		}
+
+		err := verify(ctx, []string{"1.18"})
+		assert.Equal[F](t, err.Error(), "1.18 SDK is built for plan9/386, but the host is "+hostPlatform())
+	})
+}
+
+func Test_doctor(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	t.Setenv("GOBIN", "gobin")
+	t.Setenv("PATH", "gobin"+string(filepath.Separator)) // the trailing separator doesn't matter.
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18",
+		files: []dirFile{"go1.17", "go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{
+		dir:   "sdk",
+		files: []dirFile{"go1.18/.unpacked-success"}, // 1.17 SDK is missing.
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err := doctor(ctx, nil)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, "\n"+buf.String(), `
+[OK]   $GOBIN (gobin) is in $PATH
+[OK]   $GOBIN takes precedence over other go binaries in $PATH
+[OK]   the main go binary runs
+[OK]   the go symlink points to an existing binary (1.18)
+[OK]   1.18 SDK is downloaded
+[WARN] 1.17 SDK is downloaded
+       hint: run `+"`goversion install 1.17`"+` to download it
+[OK]   SDKs are built for the host platform (`+hostPlatform()+`)
+`)
+}
+
+func Test_repair(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18", // go1.18 has been removed manually.
+		calls: &steps,
+	}
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err := current(ctx, nil)
+	assert.Equal[F](t, err.Error(), "the go symlink points to a missing binary (go1.18), run `goversion repair` to fix it")
+
+	err = repair(ctx, nil)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "Removed the dangling go symlink (go1.18), switched to 1.19 (main)\n")
+	assert.Equal[E](t, steps[len(steps)-1], "call: gobin.Remove(go)")
+}
+
+func Test_printEnv(t *testing.T) {
+	defer func(dir string) { configDir = dir }(configDir)
+	configDir = "/path/to/config"
+
+	gobin = &spyFS{dir: "gobin"}
+	sdk = &spyFS{dir: "sdk"}
+
+	t.Setenv("GOVERSION_DL_URL", "https://example.com/dl/")
+	t.Setenv("HTTPS_PROXY", "http://proxy:8080")
+	t.Setenv("GOVERSION_HTTP_TIMEOUT", "")
+	t.Setenv("GOVERSION_HTTP_RETRIES", "5")
+	t.Setenv("GOVERSION_CONCURRENCY", "8")
+	t.Setenv("GOVERSION_CACHE_TTL", "")
+	t.Setenv("GOVERSION_LINK_NAME", "golang")
+	t.Setenv("GOVERSION_NO_NETWORK", "")
+
+	var buf bytes.Buffer
+	stdout = &buf
+
+	err := printEnv(ctx, nil)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, "\n"+buf.String(), `
+gobin=gobin
+sdk_dir=sdk
+config_dir=/path/to/config
+cache_dir=
+dl_url=https://example.com/dl/?mode=json&include=all
+proxy=http://proxy:8080
+http_timeout=1m0s
+http_retries=5
+cache_ttl=1h0m0s
+concurrency=8
+link_name=golang
+no_network=false
+post_use=
+`)
+
+	buf.Reset()
+	err = printEnv(ctx, []string{"-json"})
+	assert.NoErr[F](t, err)
+
+	var vars map[string]string
+	err = json.Unmarshal(buf.Bytes(), &vars)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, vars["link_name"], "golang")
+	assert.Equal[E](t, len(vars), 13)
+
+	t.Setenv("GOVERSION_CACHE_TTL", "soon")
+	err = printEnv(ctx, nil)
+	assert.Equal[E](t, err.Error(), `invalid $GOVERSION_CACHE_TTL value "soon"`)
+}
+
+func Test_complete(t *testing.T) {
+	var steps []string
+	recordCommands(&steps)
+
+	gobin = &spyFS{
+		dir:   "gobin",
+		link:  "/path/to/go1.18",
+		files: []dirFile{"go1.17", "go1.18"},
+		calls: &steps,
+	}
+	sdk = &spyFS{dir: "sdk", calls: &steps}
+
+	var buf bytes.Buffer
+	stdout = &buf
+
+	err := complete(ctx, []string{"rm"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "1.18\n1.17\n") // the main version can't be removed.
+
+	buf.Reset()
+	err = complete(ctx, []string{"use", "-force"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "1.19\n1.18\n1.17\n") // the flags are skipped.
+
+	httpClient = &httpSpy{
+		requests: &steps,
+		response: `[{"version":"go1.21.0","stable":true},{"version":"go1.18","stable":true}]`,
+	}
+
+	buf.Reset()
+	err = complete(ctx, []string{"use", "-all"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "tip\n1.21.0\n1.18\n")
+
+	buf.Reset()
+	err = complete(ctx, []string{"use", "-all", "1.21.0"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "") // the version is already there.
+}
+
+func Test_configure(t *testing.T) {
+	defer func(dir string) { configDir = dir }(configDir)
+	configDir = t.TempDir()
+
+	var buf bytes.Buffer
+	output, stdout = &buf, &buf
+
+	err := configure(ctx, []string{"get", "default"})
+	assert.Equal[F](t, err.Error(), "default is not set")
+
+	err = configure(ctx, []string{"set", "default", "1.18"})
+	assert.NoErr[F](t, err)
+
+	err = configure(ctx, []string{"get", "default"})
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "Set default = 1.18\n1.18\n")
+
+	err = configure(ctx, []string{"set", "default", "1.18.x"})
+	assert.Equal[F](t, err.Error(), `malformed version "1.18.x"`)
+
+	err = configure(ctx, []string{"set", "editor", "vim"})
+	assert.Equal[F](t, err.Error(), `unknown config key "editor"`)
+
+	t.Run("use the default version", func(t *testing.T) {
+		t.Setenv("GOVERSION_NO_NETWORK", "1") // skip the end-of-life check.
+		// make sure there is no .go-version file to find.
+		wd, err := os.Getwd()
+		assert.NoErr[F](t, err)
+		defer os.Chdir(wd) //nolint:errcheck // the test is over anyway.
+		err = os.Chdir(t.TempDir())
+		assert.NoErr[F](t, err)
+
+		var steps []string
+		recordCommands(&steps)
+
+		gobin = &spyFS{
+			dir:   "gobin",
+			files: []dirFile{"go1.18"},
+			calls: &steps,
+		}
+		sdk = &spyFS{
+			dir:   "sdk",
+			files: []dirFile{"go1.18/.unpacked-success"},
+			calls: &steps,
+		}
+
+		buf.Reset()
+		err = use(ctx, nil)
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, buf.String(), "Switched to 1.18\n")
+	})
+}
+
+func Test_applyConfig(t *testing.T) {
+	defer func(dir string) { configDir = dir }(configDir)
+	configDir = t.TempDir()
+
+	// applyConfig modifies the environment, so the variables are restored after the test.
+	for _, key := range []string{"GOVERSION_LINK_NAME", "GOVERSION_HTTP_TIMEOUT", "GOVERSION_CACHE_TTL"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	var buf bytes.Buffer
+	output, stdout = &buf, &buf
+
+	err := configure(ctx, []string{"set", "http_timeout", "soon"})
+	assert.Equal[F](t, err.Error(), `invalid http_timeout value "soon"`)
+
+	err = configure(ctx, []string{"set", "concurrency", "0"})
+	assert.Equal[F](t, err.Error(), `invalid concurrency value "0"`)
+
+	for _, kv := range [][2]string{{"link_name", "golang"}, {"http_timeout", "5m"}, {"cache_ttl", "2h"}} {
+		err = configure(ctx, []string{"set", kv[0], kv[1]})
+		assert.NoErr[F](t, err)
+	}
+	err = configure(ctx, []string{"set", "cache_ttl", ""}) // unset.
+	assert.NoErr[F](t, err)
+
+	os.Setenv("GOVERSION_HTTP_TIMEOUT", "30s") // the environment variable takes precedence.
+
+	err = applyConfig()
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, linkName(), "golang")
+	assert.Equal[E](t, httpTimeout(), 30*time.Second)
+
+	ttl, err := cacheTTL()
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, ttl, defaultCacheTTL)
+}
+
+func Test_lock(t *testing.T) {
+	defer func(d time.Duration) { lockTimeout = d }(lockTimeout)
+	lockTimeout = 0
+
+	unlock, err := lock(ctx)
+	assert.NoErr[F](t, err)
+
+	_, err = lock(ctx)
+	assert.IsErr[E](t, err, errLocked)
+
+	unlock()
+
+	unlock, err = lock(ctx)
+	assert.NoErr[F](t, err)
+	unlock()
+}
+
+func Test_alias(t *testing.T) {
+	defer func(dir string) { configDir = dir }(configDir)
+	configDir = t.TempDir()
+
+	var buf bytes.Buffer
+	output = &buf
+
+	err := alias(ctx, []string{"stable", "1.18"})
+	assert.NoErr[F](t, err)
+	err = alias(ctx, []string{"work", "stable"})
+	assert.NoErr[F](t, err)
+
+	buf.Reset()
+	err = alias(ctx, nil)
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, buf.String(), "stable -> 1.18\nwork -> stable\n")
+
+	err = alias(ctx, []string{"stable", "work"})
+	assert.Equal[F](t, err.Error(), `alias "stable" is cyclic`)
+
+	err = alias(ctx, []string{"1.18", "1.19"})
+	assert.Equal[F](t, err.Error(), `unable to use "1.18" as an alias name`)
+
+	aliases, err := loadAliases()
+	assert.NoErr[F](t, err)
+	version, err := resolveAlias(aliases, "work")
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, version, "1.18")
+}
+
+func recordCommands(commands *[]string) {
+	command = func(ctx context.Context, env []string, name string, args ...string) error {
+		c := strings.Join(append([]string{name}, args...), " ")
+		*commands = append(*commands, "exec: "+c)
+		return nil
+	}
+	commandOutput = func(ctx context.Context, env []string, name string, args ...string) (string, error) {
+		_ = command(ctx, env, name, args...)
+		version := strings.TrimPrefix(name, "go")
+		if version == "" {
+			version = mainVersion
+		}
+		return fmt.Sprintf("go version go%s %s/%s", version, runtime.GOOS, runtime.GOARCH), nil
+	}
+}
+
+type spyFS struct {
+	dir   string
+	link  string
+	files []dirFile
+	calls *[]string
+}
+
+func (s *spyFS) Open(name string) (fs.File, error) {
+	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Open(%s)", s.dir, name))
+	for _, f := range s.files {
+		if string(f) == name {
+			return fstest.MapFS{name: {}}.Open(name) // all files are empty.
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+func (s *spyFS) Stat(name string) (fs.FileInfo, error) {
+	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Stat(%s)", s.dir, name))
+	for _, f := range s.files {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+func (s *spyFS) Remove(name string) error {
+	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Remove(%s)", s.dir, name))
+	return nil
+}
+
+func (s *spyFS) RemoveAll(name string) error {
+	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.RemoveAll(%s)", s.dir, name))
+	return nil
+}
+
+func (s *spyFS) Symlink(oldname, newname string) error {
+	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Symlink(%s, %s)", s.dir, oldname, newname))
+	return nil
+}
+
+func (s *spyFS) Rename(oldname, newname string) error {
+	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Rename(%s, %s)", s.dir, oldname, newname))
+	return nil
+}
+
+func (s *spyFS) Readlink(name string) (string, error) {
+	*s.calls = append(*s.calls, fmt.Sprintf("call: %s.Readlink(%s)", s.dir, name))
+	if s.link == "" {
+		return "", fs.ErrNotExist
 	}
 	return s.link, nil
 }
@@ -323,19 +3098,53 @@ func (s *spyFS) ReadDir(name string) ([]fs.DirEntry, error) {
 	return entries, nil
 }
 
+func (s *spyFS) Path(name string) string {
+	return s.dir + "/" + name
+}
+
 type dirFile string
 
 func (f dirFile) Name() string               { return string(f) }
 func (f dirFile) IsDir() bool                { return false }
 func (f dirFile) Type() fs.FileMode          { panic("unimplemented") }
 func (f dirFile) Info() (fs.FileInfo, error) { panic("unimplemented") }
+func (f dirFile) Size() int64                { return 0 }
+func (f dirFile) Mode() fs.FileMode          { return 0o755 }
+func (f dirFile) ModTime() time.Time         { return time.Time{} }
+func (f dirFile) Sys() any                   { return nil }
+
+// emptySHA256 is the checksum of an empty file, since all spyFS files are empty.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// archiveResponse returns the go.dev response with the archive of the given version for the current platform.
+func archiveResponse(version, sha256 string) string {
+	return fmt.Sprintf(`[{"version":"go%s","stable":true,"files":[`+
+		`{"filename":"go%[1]s.tar.gz","os":"%s","arch":"%s","kind":"archive","sha256":"%s"}]}]`,
+		version, runtime.GOOS, runtime.GOARCH, sha256)
+}
 
 type httpSpy struct {
-	requests *[]string
-	response string
+	requests    *[]string
+	response    string
+	contentType string
+	statuses    []int // returned in order before responding with 200 OK.
 }
 
 func (s *httpSpy) Do(req *http.Request) (*http.Response, error) {
 	*s.requests = append(*s.requests, "http: "+req.URL.String())
-	return &http.Response{Body: io.NopCloser(strings.NewReader(s.response))}, nil
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	header := make(http.Header)
+	if s.contentType != "" {
+		header.Set("Content-Type", s.contentType)
+	}
+	return &http.Response{
+		Status:     http.StatusText(status),
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(s.response)),
+		Request:    req,
+	}, nil
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// completion prints the completion script for the specified shell.
+// It's a hidden command, i.e. it is not mentioned in the usage.
+func completion(_ context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError{errors.New("no shell has been specified")}
+	}
+
+	var script string
+	switch shell := args[0]; shell {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+
+	fmt.Fprint(stdout, script)
+	return nil
+}
+
+// complete prints the completion candidates for the given words, one per line.
+// It's an internal command called by the completion scripts.
+func complete(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		for _, cmd := range []string{"use", "install", "upgrade", "ls", "rm", "gc", "exec", "current", "which", "alias", "lock", "config", "verify", "doctor", "env", "repair", "hook"} {
+			fmt.Fprintln(stdout, cmd)
+		}
+		return nil
+	}
+
+	// the flags before the version are skipped, -all switches to the versions from go.dev.
+	cmd, args := args[0], args[1:]
+	all := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if args[0] == "-a" || args[0] == "-all" {
+			all = true
+		}
+		args = args[1:]
+	}
+
+	// install is the only command that accepts multiple versions.
+	if len(args) > 0 && cmd != "install" {
+		return nil
+	}
+
+	switch {
+	case all && cmd == "use":
+		remote, err := remoteVersions(ctx, true)
+		if err != nil {
+			return err
+		}
+		for _, version := range remote.list {
+			fmt.Fprintln(stdout, version)
+		}
+	case cmd == "use", cmd == "rm", cmd == "exec", cmd == "verify", cmd == "which":
+		local, err := localVersions(ctx)
+		if err != nil {
+			return err
+		}
+		for _, version := range local.list {
+			// the main version can be neither removed nor verified.
+			if (cmd == "rm" || cmd == "verify") && version == local.main {
+				continue
+			}
+			fmt.Fprintln(stdout, version)
+		}
+	case cmd == "install":
+		local, err := localVersions(ctx)
+		if err != nil {
+			return err
+		}
+		remote, err := remoteVersions(ctx, true)
+		if err != nil {
+			return err
+		}
+		for _, version := range remote.list {
+			if !local.contains(version) {
+				fmt.Fprintln(stdout, version)
+			}
+		}
+	}
+
+	return nil
+}
+
+const bashCompletion = `_goversion() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	local words=$(goversion __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null)
+	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -F _goversion goversion
+`
+
+const zshCompletion = `#compdef goversion
+_goversion() {
+	local -a candidates
+	candidates=(${(f)"$(goversion __complete ${words[2,CURRENT-1]} 2>/dev/null)"})
+	compadd -a candidates
+}
+compdef _goversion goversion
+`
+
+const fishCompletion = `function __goversion_complete
+	set -l words (commandline -opc)
+	set -e words[1]
+	goversion __complete $words 2>/dev/null
+end
+complete -c goversion -f -a '(__goversion_complete)'
+`
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// config is the global configuration, set by the config command.
+// Except for default, the keys set the defaults for the environment variables (see configEnv).
+type config struct {
+	Default     string `json:"default,omitempty"` // the version to use if there is no go.mod or .go-version file.
+	GOBIN       string `json:"gobin,omitempty"`
+	SDKDir      string `json:"sdk_dir,omitempty"`
+	DLURL       string `json:"dl_url,omitempty"`
+	GOPROXY     string `json:"goproxy,omitempty"`
+	Proxy       string `json:"proxy,omitempty"`
+	HTTPTimeout string `json:"http_timeout,omitempty"`
+	HTTPRetries string `json:"http_retries,omitempty"`
+	CacheTTL    string `json:"cache_ttl,omitempty"`
+	Concurrency string `json:"concurrency,omitempty"`
+	LinkName    string `json:"link_name,omitempty"`
+	NoNetwork   string `json:"no_network,omitempty"`
+	PostUse     string `json:"post_use,omitempty"`
+}
+
+// configEnv maps the config keys to the environment variables they set the defaults for.
+// The keys are named after the settings printed by the env command.
+var configEnv = []struct {
+	key      string
+	env      string
+	validate func(string) error // nil if any value is valid.
+}{
+	{"gobin", "GOVERSION_GOBIN", nil},
+	{"sdk_dir", "GOVERSION_SDK_DIR", nil},
+	{"dl_url", "GOVERSION_DL_URL", validateURL},
+	{"goproxy", "GOVERSION_GOPROXY", nil},
+	{"proxy", "GOVERSION_PROXY", validateURL},
+	{"http_timeout", "GOVERSION_HTTP_TIMEOUT", validateDuration},
+	{"http_retries", "GOVERSION_HTTP_RETRIES", validateInt(0)},
+	{"cache_ttl", "GOVERSION_CACHE_TTL", validateDuration},
+	{"concurrency", "GOVERSION_CONCURRENCY", validateInt(1)},
+	{"link_name", "GOVERSION_LINK_NAME", nil},
+	{"no_network", "GOVERSION_NO_NETWORK", validateBool},
+	{"post_use", "GOVERSION_POST_USE", nil},
+}
+
+// field returns the pointer to the config field of the key or nil if the key is unknown.
+func (c *config) field(key string) *string {
+	fields := map[string]*string{
+		"default":      &c.Default,
+		"gobin":        &c.GOBIN,
+		"sdk_dir":      &c.SDKDir,
+		"dl_url":       &c.DLURL,
+		"goproxy":      &c.GOPROXY,
+		"proxy":        &c.Proxy,
+		"http_timeout": &c.HTTPTimeout,
+		"http_retries": &c.HTTPRetries,
+		"cache_ttl":    &c.CacheTTL,
+		"concurrency":  &c.Concurrency,
+		"link_name":    &c.LinkName,
+		"no_network":   &c.NoNetwork,
+		"post_use":     &c.PostUse,
+	}
+	return fields[key]
+}
+
+// applyConfig sets the environment variables from the config file, unless they are already set,
+// so the precedence is: the flags (e.g. -gobin), the environment variables, the config file and the built-in defaults.
+func applyConfig() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	for _, e := range configEnv {
+		value := *cfg.field(e.key)
+		if value == "" {
+			continue
+		}
+		if _, ok := os.LookupEnv(e.env); ok {
+			logger.Printf("$%s overrides %s from the config", e.env, e.key)
+			continue
+		}
+		if err := os.Setenv(e.env, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configure prints (get) or sets (set) the value of the configuration key.
+func configure(_ context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError{errors.New("no action has been specified")}
+	}
+	if len(args) == 1 {
+		return usageError{errors.New("no key has been specified")}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	action, key := args[0], args[1]
+	switch action {
+	case "get":
+		value, err := cfg.get(key)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			return fmt.Errorf("%s is not set", key)
+		}
+		fmt.Fprintln(stdout, value)
+		return nil
+	case "set":
+		if len(args) == 2 {
+			return usageError{errors.New("no value has been specified")}
+		}
+		if err := cfg.set(key, args[2]); err != nil {
+			return err
+		}
+		if err := saveConfig(cfg); err != nil {
+			return err
+		}
+		printf("Set %s = %s\n", key, args[2])
+		return nil
+	default:
+		return usageError{fmt.Errorf("unknown action %q", action)}
+	}
+}
+
+func (c *config) get(key string) (string, error) {
+	f := c.field(key)
+	if f == nil {
+		return "", fmt.Errorf("unknown config key %q", key)
+	}
+	return *f, nil
+}
+
+// set sets the value of the key, an empty value unsets it.
+func (c *config) set(key, value string) error {
+	f := c.field(key)
+	switch {
+	case f == nil:
+		return fmt.Errorf("unknown config key %q", key)
+	case value == "":
+		*f = ""
+		return nil
+	}
+
+	switch key {
+	case "default":
+		value = trimGo(value)
+		// aliases are resolved by the use command, so they are allowed here as well.
+		aliases, err := loadAliases()
+		if err != nil {
+			return err
+		}
+		if _, ok := aliases[value]; !ok && value != "main" && value != "latest" && !versionRE.MatchString(value) {
+			return malformedError{value}
+		}
+		c.Default = value
+		return nil
+	default:
+		for _, e := range configEnv {
+			if e.key == key && e.validate != nil {
+				if err := e.validate(value); err != nil {
+					return fmt.Errorf("invalid %s value %q", key, value)
+				}
+			}
+		}
+		*f = value
+		return nil
+	}
+}
+
+func validateURL(s string) error {
+	if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("malformed url")
+	}
+	return nil
+}
+
+func validateDuration(s string) error {
+	_, err := time.ParseDuration(s)
+	return err
+}
+
+func validateBool(s string) error {
+	_, err := strconv.ParseBool(s)
+	return err
+}
+
+// validateInt returns a function that checks that the value is an integer not less than lowest.
+func validateInt(lowest int) func(string) error {
+	return func(s string) error {
+		if n, err := strconv.Atoi(s); err != nil || n < lowest {
+			return errors.New("malformed integer")
+		}
+		return nil
+	}
+}
+
+func configPath() string { return filepath.Join(configDir, "config.json") }
+
+// loadConfig reads the config file, it's ok for the file to be missing.
+func loadConfig() (*config, error) {
+	data, err := os.ReadFile(configPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return new(config), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := new(config)
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", configPath(), err)
+	}
+
+	return cfg, nil
+}
+
+// saveConfig writes the config file, creating the config directory if needed.
+func saveConfig(cfg *config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(), append(data, '\n'), 0o644)
+}
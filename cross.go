@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// downloadCrossSDK downloads the SDK archive of the version for another platform (e.g. to build a container image)
+// and saves it to the SDK directory as is, returning its path.
+// golang.org/dl can only download SDKs for the platform it runs on, so the archive is downloaded from go.dev directly.
+// It's not unpacked: the SDK can't run locally anyway, and the archive name (e.g. go1.22.0.linux-arm64.tar.gz)
+// keeps it apart from the native SDK.
+func downloadCrossSDK(ctx context.Context, version, goos, goarch string) (string, error) {
+	remote, err := remoteVersions(ctx, true)
+	if err != nil {
+		return "", err
+	}
+
+	rel, ok := remote.release(version)
+	if !ok {
+		return "", notFoundError{fmt.Errorf("%s is not found on go.dev", version)}
+	}
+
+	var file releaseFile
+	for _, f := range rel.Files {
+		if f.Kind == "archive" && f.OS == goos && f.Arch == dlArch(goarch) {
+			file = f
+		}
+	}
+	if file.Filename == "" {
+		return "", notFoundError{fmt.Errorf("no %s SDK archive for %s/%s found on go.dev", version, goos, goarch)}
+	}
+
+	resp, err := httpGet(ctx, dlBaseURL()+file.Filename)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// the archive is downloaded under a temporary name, so an interrupted download is never mistaken for a complete one.
+	path := sdk.Path(file.Filename)
+	f, err := os.Create(path + ".partial")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name()) // no-op after the rename.
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), resp.Body); err != nil {
+		return "", fmt.Errorf("downloading %s SDK for %s/%s: %w", version, goos, goarch, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); got != file.SHA256 {
+		return "", fmt.Errorf("%s SDK for %s/%s checksum mismatch: want %s, got %s", version, goos, goarch, file.SHA256, got)
+	}
+	logger.Printf("verified %s SDK for %s/%s checksum: %s", version, goos, goarch, file.SHA256)
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
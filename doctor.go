@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// doctor diagnoses common setup problems and prints a checklist with remediation hints.
+// It fails if any critical check fails, non-critical ones are reported as warnings.
+func doctor(ctx context.Context, _ []string) error {
+	var failed bool
+	check := func(ok, critical bool, msg, hint string) {
+		switch {
+		case ok:
+			fmt.Fprintf(output, "[OK]   %s\n", msg)
+			return
+		case critical:
+			failed = true
+			fmt.Fprintf(output, "[FAIL] %s\n", msg)
+		default:
+			fmt.Fprintf(output, "[WARN] %s\n", msg)
+		}
+		fmt.Fprintf(output, "       hint: %s\n", hint)
+	}
+
+	gobinDir := os.Getenv("GOBIN")
+	path := filepath.SplitList(os.Getenv("PATH"))
+
+	gobinIndex := -1
+	for i, dir := range path {
+		if samePath(dir, gobinDir) {
+			gobinIndex = i
+			break
+		}
+	}
+	check(gobinIndex >= 0, true,
+		fmt.Sprintf("$GOBIN (%s) is in $PATH", gobinDir),
+		"add $GOBIN to $PATH in your shell's config",
+	)
+
+	if gobinIndex >= 0 {
+		// the go binaries located before $GOBIN in $PATH would shadow the symlink.
+		shadowedBy := ""
+		for _, dir := range path[:gobinIndex] {
+			if _, err := os.Stat(filepath.Join(dir, exe(linkName()))); err == nil {
+				shadowedBy = dir
+				break
+			}
+		}
+		check(shadowedBy == "", true,
+			"$GOBIN takes precedence over other go binaries in $PATH",
+			fmt.Sprintf("move $GOBIN before %s in $PATH", shadowedBy),
+		)
+	}
+
+	local, err := localVersions(ctx)
+	check(err == nil, true,
+		"the main go binary runs",
+		fmt.Sprintf("make sure Go is installed and `go version` works (%v)", err),
+	)
+	if err != nil {
+		return errors.New("some critical checks have failed")
+	}
+
+	switch {
+	case local.current == unknownVersion:
+		check(false, true,
+			fmt.Sprintf("the %s symlink points to a go<version> binary", linkName()),
+			"run `goversion use <version>` to repoint it",
+		)
+	case local.current != local.main:
+		version := local.current
+		if local.dangling != "" {
+			version = local.dangling
+		}
+		check(local.dangling == "", true,
+			fmt.Sprintf("the go symlink points to an existing binary (%s)", version),
+			"run `goversion repair` to reset the symlink to the main version",
+		)
+	}
+
+	var mismatched []string
+	for _, version := range local.list {
+		if version == local.main {
+			continue
+		}
+		check(downloaded(version), false,
+			fmt.Sprintf("%s SDK is downloaded", version),
+			fmt.Sprintf("run `goversion install %s` to download it", version),
+		)
+		if !downloaded(version) {
+			continue
+		}
+		// e.g. $HOME has been moved from an Intel Mac to an ARM one.
+		platform, err := sdkPlatform(ctx, version)
+		if err != nil {
+			platform = err.Error()
+		}
+		if platform != hostPlatform() {
+			mismatched = append(mismatched, fmt.Sprintf("%s (%s)", version, platform))
+		}
+	}
+
+	check(len(mismatched) == 0, false,
+		fmt.Sprintf("SDKs are built for the host platform (%s)", hostPlatform()),
+		fmt.Sprintf("re-download the mismatched SDKs with `goversion use -force <version>`: %s", strings.Join(mismatched, ", ")),
+	)
+
+	if failed {
+		return errors.New("some critical checks have failed")
+	}
+
+	return nil
+}
+
+// repair removes the go symlink if it points to a missing binary, switching back to the main version.
+func repair(ctx context.Context, _ []string) error {
+	local, err := localVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if local.dangling == "" {
+		printf("Nothing to repair\n")
+		return nil
+	}
+
+	logger.Printf("removing the dangling go symlink (go%s)", local.dangling)
+	if err := gobin.Remove(linkName()); err != nil {
+		return err
+	}
+
+	printf("Removed the dangling go symlink (go%s), switched to %s (main)\n", local.dangling, local.main)
+	return nil
+}
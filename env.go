@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// envVar is a setting printed by the env command.
+type envVar struct {
+	name  string
+	value string
+}
+
+// printEnv prints the effective settings goversion uses after applying all the environment variable overrides,
+// one name=value pair per line (like `go env`).
+// If the -json flag is provided, printEnv prints the settings to stdout as a JSON object.
+func printEnv(_ context.Context, args []string) error {
+	fset := flag.NewFlagSet("env", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	var printJSON bool
+	fset.BoolVar(&printJSON, "json", false, "print the settings in JSON format")
+
+	if err := fset.Parse(args); err != nil {
+		return usageError{err}
+	}
+
+	ttl, err := cacheTTL()
+	if err != nil {
+		return err
+	}
+
+	retries, err := httpRetries()
+	if err != nil {
+		return err
+	}
+
+	workers, err := concurrency()
+	if err != nil {
+		return err
+	}
+
+	vars := []envVar{
+		{"gobin", filepath.Clean(gobin.Path("."))},
+		{"sdk_dir", filepath.Clean(sdk.Path("."))},
+		{"config_dir", configDir},
+		{"cache_dir", cacheDir},
+		{"dl_url", remoteURL()},
+		{"proxy", os.Getenv("HTTPS_PROXY")}, // overridden by $GOVERSION_PROXY, see setProxy.
+		{"http_timeout", httpTimeout().String()},
+		{"http_retries", strconv.Itoa(retries)},
+		{"cache_ttl", ttl.String()},
+		{"concurrency", strconv.Itoa(workers)},
+		{"link_name", linkName()},
+		{"no_network", strconv.FormatBool(networkDisabled())},
+		{"post_use", os.Getenv("GOVERSION_POST_USE")},
+	}
+
+	if printJSON {
+		m := make(map[string]string, len(vars))
+		for _, v := range vars {
+			m[v.name] = v.value
+		}
+		return json.NewEncoder(stdout).Encode(m)
+	}
+
+	for _, v := range vars {
+		fmt.Fprintf(stdout, "%s=%s\n", v.name, v.value)
+	}
+	return nil
+}
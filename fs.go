@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 // fsx is an extended fs.FS that supports removing files and interacting with symlinks.
@@ -12,7 +16,9 @@ type fsx interface {
 	Remove(name string) error
 	RemoveAll(name string) error
 	Symlink(oldname, newname string) error
+	Rename(oldname, newname string) error // replaces newname if it exists.
 	Readlink(name string) (string, error)
+	Path(name string) string // returns the absolute OS-specific path of the named file.
 }
 
 // dirFSx is an extended version of os.dirFS that implements fsx.
@@ -23,10 +29,22 @@ type dirFSx struct {
 
 func dirFS(dir string) fsx { return dirFSx{os.DirFS(dir), dir} }
 
+// on Windows, symlinks require admin rights (or developer mode), so .cmd shims are used instead.
+// The shim calls the target binary, passing all the arguments through.
+const shimFormat = "@echo off\r\n\"%s\" %%*\r\n"
+
 func (dfs dirFSx) Remove(name string) error {
 	if !fs.ValidPath(name) || runtime.GOOS == "windows" && containsAny(name, `\:`) {
 		return &os.PathError{Op: "remove", Path: name, Err: os.ErrInvalid}
 	}
+	if runtime.GOOS == "windows" {
+		// the name is either a shim (see Symlink) or a binary.
+		err := os.Remove(dfs.dir + "/" + name + ".cmd")
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		name = exe(name)
+	}
 	return os.Remove(dfs.dir + "/" + name)
 }
 
@@ -44,16 +62,62 @@ func (dfs dirFSx) Symlink(oldname, newname string) error {
 	if !fs.ValidPath(newname) || runtime.GOOS == "windows" && containsAny(newname, `\:`) {
 		return &os.PathError{Op: "symlink", Path: newname, Err: os.ErrInvalid}
 	}
+	if runtime.GOOS == "windows" {
+		shim := fmt.Sprintf(shimFormat, dfs.Path(exe(oldname)))
+		return os.WriteFile(dfs.dir+"/"+newname+".cmd", []byte(shim), 0o644)
+	}
 	return os.Symlink(dfs.dir+"/"+oldname, dfs.dir+"/"+newname)
 }
 
+func (dfs dirFSx) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || runtime.GOOS == "windows" && containsAny(oldname, `\:`) {
+		return &os.PathError{Op: "rename", Path: oldname, Err: os.ErrInvalid}
+	}
+	if !fs.ValidPath(newname) || runtime.GOOS == "windows" && containsAny(newname, `\:`) {
+		return &os.PathError{Op: "rename", Path: newname, Err: os.ErrInvalid}
+	}
+	if runtime.GOOS == "windows" {
+		// the names are either shims (see Symlink) or binaries.
+		err := os.Rename(dfs.dir+"/"+oldname+".cmd", dfs.dir+"/"+newname+".cmd")
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		oldname, newname = exe(oldname), exe(newname)
+	}
+	return os.Rename(dfs.dir+"/"+oldname, dfs.dir+"/"+newname)
+}
+
 func (dfs dirFSx) Readlink(name string) (string, error) {
 	if !fs.ValidPath(name) || runtime.GOOS == "windows" && containsAny(name, `\:`) {
 		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrInvalid}
 	}
+	if runtime.GOOS == "windows" {
+		data, err := os.ReadFile(dfs.dir + "/" + name + ".cmd")
+		if err != nil {
+			return "", err
+		}
+		// the target is the only quoted part of the shim, see shimFormat.
+		parts := strings.Split(string(data), `"`)
+		if len(parts) != 3 {
+			return "", &os.PathError{Op: "readlink", Path: name, Err: errors.New("malformed shim")}
+		}
+		return strings.TrimSuffix(parts[1], ".exe"), nil
+	}
 	return os.Readlink(dfs.dir + "/" + name)
 }
 
+func (dfs dirFSx) Path(name string) string {
+	return filepath.Join(dfs.dir, filepath.FromSlash(name))
+}
+
+// exe appends the .exe suffix to the given binary name on Windows.
+func exe(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
 func containsAny(s, chars string) bool {
 	for i := 0; i < len(s); i++ {
 		for j := 0; j < len(chars); j++ {
@@ -0,0 +1,90 @@
+package main
+
+import "strings"
+
+// examples are printed along with the command's usage by `goversion <command> -h`.
+var examples = map[string][]string{
+	"use": {
+		"goversion use 1.21.3",
+		"goversion use 1.21     # the latest installed patch of 1.21",
+		"goversion use main",
+		"goversion use latest",
+		"goversion use -channel=rc latest",
+		"goversion use -prefer-installed latest",
+		"goversion use -os=linux -arch=arm64 1.22.0",
+		"goversion use -json 1.21.3  # progress events for a frontend",
+		"goversion use -        # the version that was in use before the last switch",
+		"goversion use          # the version from go.mod or .go-version",
+		"goversion use -locked  # the exact version from goversion.lock",
+	},
+	"install": {"goversion install 1.20.7 1.21.3"},
+	"upgrade": {"goversion upgrade -prune 1.21"},
+	"ls": {
+		"goversion ls",
+		"goversion ls -all -only='>=1.20'",
+		"goversion ls -remote-only -stable",
+		"goversion ls -all-patches -only=1.20",
+		"goversion ls -latest-only -all",
+		"goversion ls -sort=asc",
+		"goversion ls -count -no-main",
+		"goversion ls -json",
+	},
+	"rm": {
+		"goversion rm 1.20.7",
+		"goversion rm '1.19.*'",
+		"goversion rm -only='<1.20'",
+		"goversion rm -all-except 1.21.3",
+	},
+	"gc":      {"goversion gc -dry-run"},
+	"exec":    {"goversion exec 1.20.7 -- go test ./..."},
+	"current": {"goversion current -json"},
+	"which":   {"goversion which -sdk 1.21.3"},
+	"alias":   {"goversion alias", "goversion alias stable 1.21.3"},
+	"lock":    {"goversion lock"},
+	"config":  {"goversion config set default 1.21.3", "goversion config get default", "goversion config set http_timeout 5m"},
+	"verify":  {"goversion verify 1.21.3"},
+	"doctor":  {"goversion doctor"},
+	"env":     {"goversion env", "goversion env -json"},
+	"repair":  {"goversion repair"},
+	"hook":    {`eval "$(goversion hook zsh)"`},
+}
+
+// commandHelp returns the help message of the command: its part of the usage along with the examples.
+// If there is no such command, false is returned.
+func commandHelp(cmd string) (string, bool) {
+	_, commands, _ := strings.Cut(usage, "Commands:\n\n")
+	commands, _, _ = strings.Cut(commands, "\nFlags:")
+
+	for _, block := range strings.Split(commands, "\n\n") {
+		if fields := strings.Fields(block); len(fields) == 0 || fields[0] != cmd {
+			continue
+		}
+
+		var sb strings.Builder
+		sb.WriteString("Usage: goversion [flags] " + cmd + " [command flags]\n\n")
+		sb.WriteString(block + "\n")
+		if list := examples[cmd]; len(list) > 0 {
+			sb.WriteString("\nExamples:\n\n")
+			for _, example := range list {
+				sb.WriteString("\t" + example + "\n")
+			}
+		}
+		return sb.String(), true
+	}
+
+	return "", false
+}
+
+// helpRequested reports whether the command's arguments ask for help, e.g. `goversion use -h`.
+// The arguments after -- (e.g. passed to `exec`) are ignored.
+func helpRequested(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case "-h", "-help", "--help":
+			return true
+		}
+	}
+	return false
+}
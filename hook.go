@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// hook prints the shell snippet that runs `goversion use -if-changed` on every directory change,
+// so the version from the nearest go.mod or .go-version file is used automatically.
+func hook(_ context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError{errors.New("no shell has been specified")}
+	}
+
+	var snippet string
+	switch shell := args[0]; shell {
+	case "bash":
+		snippet = bashHook
+	case "zsh":
+		snippet = zshHook
+	default:
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+
+	fmt.Fprint(stdout, snippet)
+	return nil
+}
+
+const bashHook = `_goversion_hook() {
+	if [[ "$_goversion_last_pwd" != "$PWD" ]]; then
+		_goversion_last_pwd="$PWD"
+		goversion use -if-changed
+	fi
+}
+if [[ ";${PROMPT_COMMAND:-};" != *";_goversion_hook;"* ]]; then
+	PROMPT_COMMAND="_goversion_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`
+
+const zshHook = `_goversion_hook() {
+	goversion use -if-changed
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd _goversion_hook
+_goversion_hook
+`
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errLocked is returned if another mutating goversion command holds the lock for too long.
+var errLocked = errors.New("another goversion operation is in progress")
+
+// lockTimeout is how long lock waits for another goversion command to finish.
+var lockTimeout = 10 * time.Second
+
+// lock acquires an exclusive lock on the file in the config directory, so that
+// concurrent `use`, `install` and `rm` don't race on the symlink and SDK downloads.
+// The lock is released by the returned function or when the process exits.
+func lock(ctx context.Context) (unlock func(), err error) {
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(configDir, "lock"), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			return func() { f.Close() }, nil // closing the file releases the lock.
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w (gave up after %s)", errLocked, lockTimeout)
+		}
+		logger.Printf("waiting for another goversion operation to finish")
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lockfileName is the name of the file that pins the exact Go version for the whole team, see `use -locked`.
+const lockfileName = "goversion.lock"
+
+// lockfile writes the current Go version to the goversion.lock file in the current directory.
+// Unlike .go-version, the lockfile is only read by `use -locked`, which never resolves the version
+// (e.g. an alias or a minor release) and refuses to proceed if there is no lockfile.
+func lockfile(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError{errors.New("lock doesn't accept arguments, it records the current version")}
+	}
+
+	local, err := localVersions(ctx)
+	if err != nil {
+		return err
+	}
+	if local.dangling != "" {
+		return fmt.Errorf("the go symlink points to a missing binary (go%s), run `goversion repair` to fix it", local.dangling)
+	}
+	if local.current == unknownVersion {
+		return fmt.Errorf("the %s symlink doesn't point to a go<version> binary, switch to a version to fix it", linkName())
+	}
+	if local.current == "tip" {
+		return errors.New("unable to lock tip, it's not reproducible")
+	}
+
+	data := "# Generated by `goversion lock`, use `goversion use -locked` to switch to this version.\n" + local.current + "\n"
+	if err := os.WriteFile(lockfileName, []byte(data), 0o644); err != nil {
+		return err
+	}
+
+	printf("Locked %s in %s\n", local.current, lockfileName)
+	return nil
+}
+
+// versionFromLockfile reads the version from the goversion.lock file,
+// starting from the given directory and walking up to the root.
+func versionFromLockfile(dir string) (string, error) {
+	for {
+		name := filepath.Join(dir, lockfileName)
+		data, err := os.ReadFile(name)
+		switch {
+		case err == nil:
+			return parseLockfile(name, string(data))
+		case !errors.Is(err, fs.ErrNotExist):
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found: %w", lockfileName, fs.ErrNotExist)
+		}
+		dir = parent
+	}
+}
+
+// parseLockfile returns the only version from the lockfile, ignoring comments and empty lines.
+func parseLockfile(name, data string) (string, error) {
+	var versions []string
+	for _, line := range strings.Split(data, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			versions = append(versions, line)
+		}
+	}
+
+	switch {
+	case len(versions) != 1:
+		return "", fmt.Errorf("%s: want exactly one version, got %d", name, len(versions))
+	case versions[0] == "tip" || !versionRE.MatchString(versions[0]):
+		return "", fmt.Errorf("%s: %w", name, malformedError{versions[0]})
+	}
+
+	return versions[0], nil
+}
@@ -8,11 +8,19 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 var Version = "dev" // injected at build time.
@@ -25,20 +33,23 @@ func main() {
 		case errors.Is(err, flag.ErrHelp):
 			fmt.Fprintf(output, "%s", usage)
 			os.Exit(0)
+		case errors.Is(err, context.Canceled):
+			fmt.Fprintf(output, "canceled\n")
+			os.Exit(exitFailure)
 		case errors.As(err, new(usageError)):
 			fmt.Fprintf(output, "Error: %v\n\n%s", err, usage)
-			os.Exit(2)
+			os.Exit(exitUsage)
 		case errors.As(err, &exitErr):
 			code := exitErr.ExitCode()
 			os.Exit(code)
 		default:
 			fmt.Fprintf(output, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 	}
 }
 
-func run() error {
+func run() (err error) {
 	fset := flag.NewFlagSet("goversion", flag.ContinueOnError)
 	fset.SetOutput(io.Discard)
 
@@ -46,10 +57,36 @@ func run() error {
 	fset.BoolVar(&printVersion, "v", false, "shorthand for -version")
 	fset.BoolVar(&printVersion, "version", false, "print the version of goversion itself and quit")
 
+	// -v is already taken by -version, so there is no shorthand.
+	var verbose bool
+	fset.BoolVar(&verbose, "verbose", false, "print the details of each step")
+
+	fset.BoolVar(&quiet, "q", false, "shorthand for -quiet")
+	fset.BoolVar(&quiet, "quiet", false, "do not print informational messages")
+
+	fset.BoolVar(&noColor, "no-color", false, "do not colorize the output")
+
+	fset.BoolVar(&noCache, "no-cache", false, "do not use the cached data (the main version, go env GOBIN and the list from go.dev)")
+
+	var gobinFlag string
+	fset.StringVar(&gobinFlag, "gobin", "", "use this directory instead of $GOBIN")
+
+	var timeout time.Duration
+	fset.DurationVar(&timeout, "timeout", 0, "abort the command if it takes longer than this (e.g. 2m)")
+
 	if err := fset.Parse(os.Args[1:]); err != nil {
 		return usageError{err}
 	}
 
+	if q, _ := strconv.ParseBool(os.Getenv("GOVERSION_QUIET")); q {
+		quiet = true
+	}
+
+	if verbose {
+		logger.SetOutput(output)
+		streamOutput = true
+	}
+
 	if printVersion {
 		fmt.Fprintf(output, "goversion %s %s/%s\n", Version, runtime.GOOS, runtime.GOARCH)
 		return nil
@@ -60,60 +97,460 @@ func run() error {
 		return usageError{errors.New("no command has been specified")}
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	if helpRequested(args[1:]) {
+		if help, ok := commandHelp(args[0]); ok {
+			fmt.Fprintf(output, "%s", help)
+			return nil
+		}
+	}
+
+	// the child processes (e.g. `go<version> download`) are killed when the context is canceled.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		// the error itself is usually not helpful, e.g. `signal: killed` from a child process.
+		switch {
+		case err == nil:
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			err = fmt.Errorf("timed out after %s", timeout)
+		case errors.Is(ctx.Err(), context.Canceled):
+			err = context.Canceled
+		}
+	}()
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		panic(err)
 	}
 
-	gobinDir, ok := os.LookupEnv("GOBIN")
-	if !ok {
-		gobinDir = filepath.Join(home, "go", "bin")
-		os.Setenv("GOBIN", gobinDir)
+	if configDir, cacheDir, err = stateDirs(); err != nil {
+		return err
+	}
+
+	// the config file must be applied before anything reads the environment variables (e.g. $GOVERSION_GOBIN).
+	if err := applyConfig(); err != nil {
+		return err
+	}
+
+	// the flag allows switching between several toolchain roots (e.g. one per project) without exporting $GOBIN.
+	gobinDir := gobinFlag
+	if gobinDir == "" {
+		if gobinDir, err = resolveGOBIN(ctx, home); err != nil {
+			return err
+		}
+	} else if gobinDir, err = filepath.Abs(gobinDir); err != nil { // `go install` requires an absolute $GOBIN.
+		return err
+	}
+	// make sure `go install` and $PATH manipulation use the same directory.
+	os.Setenv("GOBIN", gobinDir)
+
+	if err := setProxy(); err != nil {
+		return err
 	}
 
+	// the default transport is cloned to keep its settings (e.g. timeouts and HTTP/2) along with the explicit proxy.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	httpClient = &http.Client{Timeout: httpTimeout(), Transport: transport}
+
 	// TODO(junk1tm): rewrite when https://github.com/golang/go/issues/26520 is closed.
 	sdkDir := filepath.Join(home, "sdk")
+	if dir := os.Getenv("GOVERSION_SDK_DIR"); dir != "" {
+		sdkDir = dir
+	}
 
 	// TODO(junk1tm): make sure it works on Windows
 	// (see https://github.com/golang/go/issues/44279).
 	gobin, sdk = dirFS(gobinDir), dirFS(sdkDir)
 
+	// a dry run changes nothing on disk, so it needs neither the GOBIN check (which may create the directory) nor the lock.
+	dryRun := dryRunRequested(args[1:])
+
+	// report a missing or read-only $GOBIN up front instead of failing in the middle of the command.
+	switch args[0] {
+	case "use", "install", "upgrade", "rm", "repair":
+		if !dryRun {
+			if err := checkGOBIN(gobinDir); err != nil {
+				return err
+			}
+		}
+	}
+
+	// only the commands that modify the symlink or SDKs need the lock.
+	switch args[0] {
+	case "use", "install", "upgrade", "rm", "gc", "repair":
+		if !dryRun {
+			unlock, err := lock(ctx)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+	}
+
 	switch cmd := args[0]; cmd {
 	case "use":
 		return use(ctx, args[1:])
+	case "install":
+		return install(ctx, args[1:])
+	case "upgrade":
+		return upgrade(ctx, args[1:])
 	case "ls":
 		return list(ctx, args[1:])
 	case "rm":
 		return remove(ctx, args[1:])
+	case "gc":
+		return gc(ctx, args[1:])
+	case "lock":
+		return lockfile(ctx, args[1:])
+	case "current":
+		return current(ctx, args[1:])
+	case "exec":
+		return execute(ctx, args[1:])
+	case "which":
+		return which(ctx, args[1:])
+	case "alias":
+		return alias(ctx, args[1:])
+	case "config":
+		return configure(ctx, args[1:])
+	case "verify":
+		return verify(ctx, args[1:])
+	case "doctor":
+		return doctor(ctx, args[1:])
+	case "env":
+		return printEnv(ctx, args[1:])
+	case "repair":
+		return repair(ctx, args[1:])
+	case "hook":
+		return hook(ctx, args[1:])
+	case "completion":
+		return completion(ctx, args[1:])
+	case "__complete":
+		return complete(ctx, args[1:])
 	default:
 		return usageError{fmt.Errorf("unknown command %q", cmd)}
 	}
 }
 
-var output io.Writer = os.Stderr
+// resolveGOBIN returns the directory where Go binaries are installed.
+// The lookup order is $GOVERSION_GOBIN, $GOBIN, `go env GOBIN`, $GOPATH/bin and $HOME/go/bin.
+func resolveGOBIN(ctx context.Context, home string) (string, error) {
+	for _, key := range []string{"GOVERSION_GOBIN", "GOBIN"} {
+		if dir := os.Getenv(key); dir != "" {
+			return dir, nil
+		}
+	}
+
+	// GOBIN could be set via `go env -w`.
+	// spawning `go env` is slow (e.g. for a shell prompt), so its result is cached (see gobinCacheKey).
+	key := gobinCacheKey()
+	out, ok := readCachedValue("gobin", key)
+	if ok {
+		logger.Printf("using the cached `go env GOBIN` output %q", out)
+	} else {
+		var err error
+		out, err = commandOutput(ctx, nil, "go", "env", "GOBIN")
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", errNoMainGo
+		}
+		if err != nil {
+			return "", err
+		}
+		writeCachedValue("gobin", key, out)
+	}
+	if dir := strings.TrimSpace(out); dir != "" {
+		return dir, nil
+	}
+
+	// only the first entry of $GOPATH is used by `go install`.
+	if gopath := filepath.SplitList(os.Getenv("GOPATH")); len(gopath) > 0 && gopath[0] != "" {
+		return filepath.Join(gopath[0], "bin"), nil
+	}
+
+	return filepath.Join(home, "go", "bin"), nil
+}
+
+// dryRunRequested reports whether the command's arguments include the -dry-run flag, e.g. `goversion use -dry-run 1.18`.
+// Like in helpRequested, the arguments after -- are ignored.
+func dryRunRequested(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "dry-run" {
+			continue
+		}
+		if !hasValue {
+			return true
+		}
+		b, err := strconv.ParseBool(value)
+		return err == nil && b
+	}
+	return false
+}
+
+// checkGOBIN makes sure the GOBIN directory exists and is writable.
+// A missing directory is created, unless $GOVERSION_NO_CREATE_GOBIN is set to a true value (e.g. 1).
+func checkGOBIN(dir string) error {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		if noCreate, _ := strconv.ParseBool(os.Getenv("GOVERSION_NO_CREATE_GOBIN")); noCreate {
+			return fmt.Errorf("GOBIN directory %s does not exist", dir)
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating GOBIN directory %s: %w", dir, err)
+		}
+		logger.Printf("created GOBIN directory %s", dir)
+	}
+
+	// checking the permission bits is not enough (ACLs, read-only mounts, Windows), so just try to write.
+	f, err := os.CreateTemp(dir, ".goversion-*")
+	if err != nil {
+		return fmt.Errorf("GOBIN directory %s is not writable: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// setProxy overrides $HTTPS_PROXY and $HTTP_PROXY with $GOVERSION_PROXY, if it's set
+// (e.g. http://proxy:8080 or socks5://proxy:1080), so a different proxy than the rest of the environment can be used.
+// The variables are overridden instead of configuring the HTTP client only, so the child processes
+// (e.g. `go<version> download`) use the same proxy, and $NO_PROXY is honored everywhere.
+func setProxy() error {
+	proxy := os.Getenv("GOVERSION_PROXY")
+	if proxy == "" {
+		return nil
+	}
+	if u, err := url.Parse(proxy); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid $GOVERSION_PROXY value %q", proxy)
+	}
+	for _, key := range []string{"HTTPS_PROXY", "HTTP_PROXY"} {
+		if err := os.Setenv(key, proxy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stateDirs returns the directories where goversion writes its state and caches.
+// If $GOVERSION_HOME is set, both are placed under it, so the real home directory stays untouched.
+func stateDirs() (config, cache string, err error) {
+	if home := os.Getenv("GOVERSION_HOME"); home != "" {
+		return home, filepath.Join(home, "cache"), nil
+	}
+
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	return filepath.Join(userConfigDir, "goversion"), filepath.Join(userCacheDir, "goversion"), nil
+}
+
+var (
+	output io.Writer = os.Stderr
+	stdout io.Writer = os.Stdout // for the output meant to be consumed by scripts.
+	stdin  io.Reader = os.Stdin  // for the interactive picker, see pickVersion.
+	quiet  bool                  // set by the -quiet flag.
+)
+
+// printf prints an informational message to the output unless the -quiet flag is provided.
+func printf(format string, args ...any) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(output, format, args...)
+}
+
+// logger prints the details of each step, it discards everything unless the -verbose flag is provided.
+var logger = log.New(io.Discard, "", log.LstdFlags|log.Lmicroseconds)
 
 const usage = `Usage: goversion [flags] <command> [command flags]
 
 Commands:
 
 	use <version>        switch the current Go version (will be installed if not already exists)
+	                     (use "main" for the main version, "latest" for the latest stable one
+	                     and "-" for the version that was in use before the last switch;
+	                     a minor release like 1.20 is resolved to its latest installed patch
+	                     or, if none, to the latest one from go.dev)
+	    -if-changed      do nothing if the version is already in use (useful for shell hooks)
+	    -force           re-download the SDK even if it's already downloaded
+	    -no-checksum     do not verify the downloaded SDK against the checksum from go.dev
+	    -print-path      print the export PATH line to eval (to stdout) instead of switching the symlink
+	    -dry-run         print what would be done (prefixed with [dry-run]) without doing it
+	    -no-switch       install the version and download its SDK but do not switch to it
+	    -locked          switch to the exact version from the goversion.lock file
+	                     (fails if there is none, the version is never resolved)
+	    -quiet-if-current
+	                     print nothing if the version is already in use (switches are still printed)
+	    -channel=<name>  the release channel for "latest": stable (default), rc or beta
+	    -prefer-installed
+	                     resolve "latest" to the newest installed stable version without going online
+	                     (go.dev is only queried if none is installed)
+	    -json            print the progress as newline-delimited JSON events (to stdout)
+	                     instead of the messages: installing, downloading, switched, ready or unchanged
+	    -os=<name>       download the SDK archive for this OS instead of switching (e.g. linux)
+	    -arch=<name>     download the SDK archive for this architecture instead of switching (e.g. arm64)
+
+	install <versions>   install the specified Go versions concurrently (without switching)
+	    -no-checksum     do not verify the downloaded SDKs against the checksums from go.dev
+
+	upgrade <version>    install the latest patch of the minor release (switch to it if it's in use)
+	    -prune           remove the older patches of the minor release
+	    -no-checksum     do not verify the downloaded SDK against the checksum from go.dev
 
 	ls                   print the list of installed Go versions
 	    -a (-all)        print available versions from go.dev as well
+	    -installed-only  print only installed versions (default)
+	    -remote-only     print only available versions from go.dev, without local annotations
 	    -only=<prefix>   print only versions starting with this prefix
+	                     (or matching a comparison, e.g. -only='>=1.20')
+	    -no-cache        do not use the cached data (the same as the global -no-cache flag)
+	    -offline-fallback
+	                     use the cached list of available versions if go.dev is not reachable
+	    -json            print versions in JSON format (to stdout)
+	    -size            print the disk space used by each SDK
+	    -outdated        print only installed versions that have newer patches
+	    -group           group versions by minor release
+	    -format=<tmpl>   print each version using the Go template (to stdout),
+	                     e.g. -format='{{.Version}}{{if .Current}} *{{end}}'
+	    -stable          print only stable versions from go.dev (with -all or -remote-only)
+	    -prerelease      print only rc/beta versions (with -all or -remote-only)
+	    -sort=<order>    sort versions newest-first (desc, default, the order ls has always used)
+	                     or oldest-first (asc)
+	    -all-patches     print all patches of the minor release from -only (e.g. -only=1.20),
+	                     both installed and available on go.dev
+	    -count           print only the number of versions (to stdout), e.g. for monitoring
+	    -no-main         exclude the main version
+	    -latest-only     print only the newest patch of each minor release (e.g. 1.20.7 but not 1.20.1)
+	    -porcelain       print versions in a stable format for scripts (to stdout),
+	                     one "<version><tab><flags>" line per version (see README)
 
 	rm <version>         remove the specified Go version (both the binary and the SDK)
+	                     (the version can be a glob pattern, e.g. '1.19.*')
+	    -only=<prefix>   remove all installed versions starting with this prefix
+	                     (or matching a comparison, e.g. -only='<1.19')
+	    -all-except      remove all installed versions except the specified ones (and main)
+	    -keep-sdk        remove only the binary, keeping the SDK for later
+	    -fallback=<name> the version to switch to if the current one is removed:
+	                     main (default) or latest (the newest installed one)
+
+	gc                   remove orphaned SDKs (with no matching go<version> binary)
+	    -dry-run         print what would be removed without removing it
+
+	exec <version> -- <command>
+	                     run the command with the specified Go version (without switching)
+	    -no-checksum     do not verify the downloaded SDK against the checksum from go.dev
+
+	current              print the current Go version (to stdout, without decorations)
+	    -json            print the version in JSON format
+
+	which <version>      print the path of the specified Go version's binary (to stdout)
+	    -sdk             print the path of the SDK's go binary instead
+	    -json            print the version and the path in JSON format
+
+	alias [name version] print the list of aliases or set the alias (can be used instead of a version)
+
+	lock                 write the current Go version to the goversion.lock file in the current directory
+
+	config get <key>     print the value of the config key (to stdout)
+	config set <key> <value>
+	                     set the value of the config key (an empty value unsets it):
+	                     "default" is the version to use if there is no go.mod or .go-version file,
+	                     the others set the defaults for the $GOVERSION_* variables (see README)
+
+	verify <version>     check the integrity of the specified Go version's SDK
+
+	doctor               diagnose common setup problems
+
+	env                  print the effective settings after applying the environment variables (to stdout)
+	    -json            print the settings in JSON format
+
+	repair               remove the go symlink if it points to a missing binary
+
+	hook <shell>         print the snippet that switches versions on cd (bash or zsh)
 
 Flags:
 
 	-h (-help)           print this message and quit
+	                     (use "goversion <command> -h" for the help of the command with examples)
 	-v (-version)        print the version of goversion itself and quit
+	-verbose             print the details of each step (to stderr)
+	                     and stream the output of go install and SDK downloads live
+	-q (-quiet)          do not print informational messages (errors are still printed)
+	-no-color            do not colorize the output (colors are only used in a terminal,
+	                     $NO_COLOR and CLICOLOR=0 are honored as well)
+	-no-cache            do not use the cached data (the main version, go env GOBIN and the list from go.dev)
+	-gobin=<dir>         use this directory instead of $GOBIN (takes precedence over $GOVERSION_GOBIN)
+	-timeout=<duration>  abort the command if it takes longer than this (e.g. 2m),
+	                     a partially downloaded SDK is removed
+
+Exit codes:
+
+	1                    any other failure
+	2                    invalid usage
+	3                    the version is not installed or not found on go.dev
+	4                    go.dev is not reachable or network access is disabled
+	5                    the version is malformed
 `
 
+// The exit codes, so scripts can tell the failures apart.
+// If a command run by goversion fails, its exit code is used instead.
+const (
+	exitFailure   = 1 // any other failure.
+	exitUsage     = 2 // see usageError.
+	exitNotFound  = 3 // see notFoundError.
+	exitNetwork   = 4 // see networkError.
+	exitMalformed = 5 // see malformedError.
+)
+
+// exitCode returns the exit code for the given error.
+func exitCode(err error) int {
+	switch {
+	case errors.As(err, new(usageError)):
+		return exitUsage
+	case errors.As(err, new(notFoundError)):
+		return exitNotFound
+	case errors.As(err, new(networkError)):
+		return exitNetwork
+	case errors.As(err, new(malformedError)):
+		return exitMalformed
+	default:
+		return exitFailure
+	}
+}
+
+// usageError is returned if the command line is invalid, the usage is printed along with it.
 type usageError struct{ err error }
 
 func (e usageError) Error() string { return e.err.Error() }
 func (e usageError) Unwrap() error { return e.err }
+
+// notFoundError is returned if the version is not installed or not found on go.dev.
+type notFoundError struct{ err error }
+
+func (e notFoundError) Error() string { return e.err.Error() }
+func (e notFoundError) Unwrap() error { return e.err }
+
+// networkError is returned if go.dev (or the mirror) can't be reached or network access is disabled.
+type networkError struct{ err error }
+
+func (e networkError) Error() string { return e.err.Error() }
+func (e networkError) Unwrap() error { return e.err }
+
+// malformedError is returned if the version is malformed.
+type malformedError struct{ version string }
+
+func (e malformedError) Error() string { return fmt.Sprintf("malformed version %q", e.version) }
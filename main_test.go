@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-simpler/assert"
+	. "github.com/go-simpler/assert/dotimport"
+)
+
+func TestMain(m *testing.M) {
+	// commands store their state in the config directory, so it must not point to the real one.
+	dir, err := os.MkdirTemp("", "goversion")
+	if err != nil {
+		panic(err)
+	}
+	configDir = dir
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func Test_resolveGOBIN(t *testing.T) {
+	t.Run("$GOBIN is set", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+
+		t.Setenv("GOVERSION_GOBIN", "")
+		t.Setenv("GOBIN", "/path/to/gobin")
+
+		dir, err := resolveGOBIN(ctx, "/home")
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, dir, "/path/to/gobin")
+		assert.Equal[E](t, len(steps), 0)
+	})
+
+	t.Run("$GOBIN is unset", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+		commandOutput = func(_ context.Context, env []string, name string, args ...string) (string, error) {
+			return "\n", command(ctx, env, name, args...)
+		}
+
+		t.Setenv("GOVERSION_GOBIN", "")
+		t.Setenv("GOBIN", "")
+		t.Setenv("GOPATH", "/path/to/gopath")
+
+		dir, err := resolveGOBIN(ctx, "/home")
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, dir, filepath.Join("/path/to/gopath", "bin"))
+		assert.Equal[E](t, steps, []string{
+			"exec: go env GOBIN", // 1. read GOBIN from the go env file
+		})
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		var steps []string
+		recordCommands(&steps)
+		commandOutput = func(_ context.Context, env []string, name string, args ...string) (string, error) {
+			return "/path/to/gobin\n", command(ctx, env, name, args...)
+		}
+
+		defer func(dir string) { cacheDir = dir }(cacheDir)
+		cacheDir = t.TempDir()
+
+		// the go binary is never run, it's only looked for in $PATH.
+		bin := t.TempDir()
+		err := os.WriteFile(filepath.Join(bin, exe("go")), nil, 0o755)
+		assert.NoErr[F](t, err)
+		t.Setenv("PATH", bin)
+		t.Setenv("GOENV", filepath.Join(t.TempDir(), "env"))
+		t.Setenv("GOVERSION_GOBIN", "")
+		t.Setenv("GOBIN", "")
+
+		for _, wantRun := range []bool{true, false} {
+			steps = nil
+			dir, err := resolveGOBIN(ctx, "/home")
+			assert.NoErr[F](t, err)
+			assert.Equal[E](t, dir, "/path/to/gobin")
+			assert.Equal[E](t, len(steps) == 1, wantRun)
+		}
+
+		// `go env -w` invalidates the cache.
+		err = os.WriteFile(os.Getenv("GOENV"), []byte("GOBIN=/path/to/gobin\n"), 0o644)
+		assert.NoErr[F](t, err)
+		steps = nil
+		_, err = resolveGOBIN(ctx, "/home")
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, len(steps), 1)
+	})
+}
+
+func Test_noMainGo(t *testing.T) {
+	commandOutput = func(context.Context, []string, string, ...string) (string, error) {
+		return "", &exec.Error{Name: "go", Err: exec.ErrNotFound}
+	}
+
+	t.Setenv("GOVERSION_GOBIN", "")
+	t.Setenv("GOBIN", "")
+
+	_, err := resolveGOBIN(ctx, "/home")
+	assert.IsErr[E](t, err, errNoMainGo)
+	assert.Equal[E](t, exitCode(err), exitNotFound)
+
+	gobin = &spyFS{dir: "gobin", calls: new([]string)}
+	_, err = localVersions(ctx)
+	assert.IsErr[E](t, err, errNoMainGo)
+}
+
+func Test_setProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://corporate:8080")
+	t.Setenv("HTTP_PROXY", "http://corporate:8080")
+
+	t.Setenv("GOVERSION_PROXY", "")
+	err := setProxy()
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, os.Getenv("HTTPS_PROXY"), "http://corporate:8080")
+
+	t.Setenv("GOVERSION_PROXY", "socks5://localhost:1080")
+	err = setProxy()
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, os.Getenv("HTTPS_PROXY"), "socks5://localhost:1080")
+	assert.Equal[E](t, os.Getenv("HTTP_PROXY"), "socks5://localhost:1080")
+
+	t.Setenv("GOVERSION_PROXY", "localhost")
+	err = setProxy()
+	assert.Equal[E](t, err.Error(), `invalid $GOVERSION_PROXY value "localhost"`)
+}
+
+func Test_stateDirs(t *testing.T) {
+	t.Setenv("GOVERSION_HOME", "/path/to/home")
+
+	config, cache, err := stateDirs()
+	assert.NoErr[F](t, err)
+	assert.Equal[E](t, config, "/path/to/home")
+	assert.Equal[E](t, cache, filepath.Join("/path/to/home", "cache"))
+}
+
+func Test_checkGOBIN(t *testing.T) {
+	t.Run("existing directory", func(t *testing.T) {
+		dir := t.TempDir()
+		err := checkGOBIN(dir)
+		assert.NoErr[F](t, err)
+
+		entries, err := os.ReadDir(dir)
+		assert.NoErr[F](t, err)
+		assert.Equal[E](t, len(entries), 0)
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "go", "bin")
+		err := checkGOBIN(dir)
+		assert.NoErr[F](t, err)
+
+		_, err = os.Stat(dir)
+		assert.NoErr[F](t, err)
+	})
+
+	t.Run("missing directory without creating", func(t *testing.T) {
+		t.Setenv("GOVERSION_NO_CREATE_GOBIN", "1")
+
+		dir := filepath.Join(t.TempDir(), "bin")
+		err := checkGOBIN(dir)
+		assert.Equal[E](t, err.Error(), fmt.Sprintf("GOBIN directory %s does not exist", dir))
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "bin")
+		err := os.WriteFile(file, nil, 0o644)
+		assert.NoErr[F](t, err)
+
+		err = checkGOBIN(file)
+		assert.Equal[E](t, strings.HasPrefix(err.Error(), "GOBIN directory "+file+" is not writable"), true)
+	})
+}
+
+func Test_exitCode(t *testing.T) {
+	test := func(err error, want int) {
+		t.Helper()
+		assert.Equal[E](t, exitCode(err), want)
+	}
+
+	test(errors.New("oops"), exitFailure)
+	test(usageError{errors.New("no version has been specified")}, exitUsage)
+	test(notFoundError{errors.New("1.18 is not installed")}, exitNotFound)
+	test(fmt.Errorf("1.18 is not installed: %w", errNoNetwork), exitNetwork)
+	test(malformedError{"go1.18"}, exitMalformed)
+}
+
+func Test_commandHelp(t *testing.T) {
+	help, ok := commandHelp("ls")
+	assert.Equal[F](t, ok, true)
+	assert.Equal[E](t, strings.HasPrefix(help, "Usage: goversion [flags] ls [command flags]\n\n\tls "), true)
+	assert.Equal[E](t, strings.Contains(help, "\t    -a (-all) "), true)
+	assert.Equal[E](t, strings.Contains(help, "\t    -only=<prefix> "), true)
+	assert.Equal[E](t, strings.Contains(help, "\nExamples:\n\n\tgoversion ls\n"), true)
+
+	// every command with examples must be documented in the usage.
+	for cmd := range examples {
+		_, ok := commandHelp(cmd)
+		assert.Equal[E](t, ok, true)
+	}
+
+	_, ok = commandHelp("unknown")
+	assert.Equal[E](t, ok, false)
+}
+
+func Test_dryRunRequested(t *testing.T) {
+	test := func(args []string, want bool) {
+		t.Helper()
+		assert.Equal[E](t, dryRunRequested(args), want)
+	}
+
+	test([]string{"-dry-run", "1.18"}, true)
+	test([]string{"-force", "--dry-run", "1.18"}, true)
+	test([]string{"-dry-run=true"}, true)
+	test([]string{"-dry-run=false", "1.18"}, false)
+	test([]string{"1.18"}, false)
+	test([]string{"1.18", "--", "tool", "-dry-run"}, false) // the flag is for the command run by exec.
+}
+
+func Test_helpRequested(t *testing.T) {
+	test := func(args []string, want bool) {
+		t.Helper()
+		assert.Equal[E](t, helpRequested(args), want)
+	}
+
+	test([]string{"-h"}, true)
+	test([]string{"-force", "-help", "1.18"}, true)
+	test([]string{"--help"}, true)
+	test([]string{"1.18"}, false)
+	test([]string{"1.18", "--", "go", "-h"}, false) // the flag is for the command run by exec.
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// interactive reports whether the user can be asked to pick a version,
+// i.e. both stdin and stdout are terminals.
+func interactive() bool { return isTerminal(stdin) && isTerminal(stdout) }
+
+// pickVersion prints the numbered list of installed versions to w and reads the user's choice from r.
+// An empty choice selects the current version.
+func pickVersion(local *local, r io.Reader, w io.Writer) (string, error) {
+	def := 0
+	fmt.Fprintf(w, "Select a Go version:\n")
+	for i, version := range local.list {
+		prefix, extra := " ", ""
+		if version == local.current {
+			prefix, def = "*", i+1
+		}
+		if version == local.main {
+			extra = " (main)"
+		}
+		fmt.Fprintf(w, "%s %2d) %s%s\n", prefix, i+1, version, extra)
+	}
+	fmt.Fprintf(w, "Enter a number [%d]: ", def)
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	choice := strings.TrimSpace(line)
+	if choice == "" {
+		if def == 0 {
+			return "", errors.New("no version has been selected")
+		}
+		return local.list[def-1], nil
+	}
+
+	n, err := strconv.Atoi(choice)
+	if err != nil || n < 1 || n > len(local.list) {
+		return "", fmt.Errorf("invalid choice %q", choice)
+	}
+	return local.list[n-1], nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// withProgress calls fn, printing the elapsed time to the output every second until fn returns.
+// The progress is only shown if the output is a terminal (and -quiet is not provided), so CI logs stay clean.
+func withProgress(msg string, fn func() error) error {
+	if quiet || !isTerminal(output) {
+		return fn()
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		start := time.Now()
+		for {
+			select {
+			case <-done:
+				fmt.Fprint(output, "\r\033[K") // clear the line.
+				return
+			case <-ticker.C:
+				fmt.Fprintf(output, "\r\033[K%s %s", msg, time.Since(start).Round(time.Second))
+			}
+		}
+	}()
+
+	err := fn()
+	close(done)
+	<-stopped
+	return err
+}
+
+// isTerminal reports whether the given value is a file referring to a terminal.
+func isTerminal(v any) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -18,12 +19,20 @@ func versionLess(a, b string) bool {
 	majb, minb, tb := parseVersion(b)
 	if maja == majb {
 		if mina == minb {
-			if ta == "" {
+			if ta == tb {
+				return false
+			} else if ta == "" {
 				return true
 			} else if tb == "" {
 				return false
 			}
-			return ta >= tb
+			// rc is newer than beta, and the numbers must be compared as integers (rc10 > rc2).
+			ka, na := parseTail(ta)
+			kb, nb := parseTail(tb)
+			if ka == kb {
+				return na > nb
+			}
+			return ka > kb
 		}
 		return mina >= minb
 	}
@@ -47,3 +56,53 @@ func parseVersion(v string) (maj, min int, tail string) {
 	min, _ = strconv.Atoi(p[1])
 	return
 }
+
+func parseTail(tail string) (kind string, n int) {
+	i := strings.IndexAny(tail, "0123456789")
+	if i < 0 {
+		return tail, 0
+	}
+	n, _ = strconv.Atoi(tail[i:])
+	return tail[:i], n
+}
+
+// minorRelease returns the minor release of the given version, e.g. 1.20 for 1.20.3 and 1.20rc1.
+func minorRelease(v string) string {
+	if i := strings.IndexAny(v, "br"); i > 0 {
+		v = v[:i] // cut beta/rc.
+	}
+	if p := strings.Split(v, "."); len(p) > 2 {
+		v = p[0] + "." + p[1]
+	}
+	return v
+}
+
+// versionFilter parses the value of the -only flag: either a comparison with a version (e.g. >=1.20 or <1.19)
+// or a plain prefix (e.g. 1.18), which is kept for backward compatibility.
+func versionFilter(expr string) (func(version string) bool, error) {
+	// the two-character operators must go first, since they start with the one-character ones.
+	for _, op := range []string{">=", "<=", "==", ">", "<", "="} {
+		target := strings.TrimPrefix(expr, op)
+		if target == expr {
+			continue
+		}
+		target = strings.TrimSpace(target)
+		if !versionRE.MatchString(target) {
+			return nil, fmt.Errorf("malformed version %q in %q", target, expr)
+		}
+		// versionLess puts newer versions first, so versionLess(a, b) means a > b.
+		switch op {
+		case ">=":
+			return func(v string) bool { return !versionLess(target, v) }, nil
+		case "<=":
+			return func(v string) bool { return !versionLess(v, target) }, nil
+		case ">":
+			return func(v string) bool { return versionLess(v, target) }, nil
+		case "<":
+			return func(v string) bool { return versionLess(target, v) }, nil
+		default:
+			return func(v string) bool { return v == target }, nil
+		}
+	}
+	return func(v string) bool { return strings.HasPrefix(v, expr) }, nil
+}
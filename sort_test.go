@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/go-simpler/assert"
+	. "github.com/go-simpler/assert/dotimport"
+)
+
+func Test_versionLess(t *testing.T) {
+	versions := []string{"1.21rc2", "1.20", "1.21", "1.21beta1", "tip", "1.21rc10", "1.21rc1", "1.20.1"}
+	sort.Slice(versions, func(i, j int) bool {
+		return versionLess(versions[i], versions[j])
+	})
+	assert.Equal[E](t, versions, []string{"tip", "1.21", "1.21rc10", "1.21rc2", "1.21rc1", "1.21beta1", "1.20.1", "1.20"})
+}
+
+func Test_minorRelease(t *testing.T) {
+	test := func(version, want string) {
+		t.Helper()
+		assert.Equal[E](t, minorRelease(version), want)
+	}
+
+	test("1", "1")
+	test("1.20", "1.20")
+	test("1.20.3", "1.20")
+	test("1.21rc1", "1.21")
+	test("1.21beta1", "1.21")
+}
+
+func Test_versionFilter(t *testing.T) {
+	versions := []string{"1.21", "1.20.1", "1.20", "1.19.5", "1.18"}
+
+	test := func(expr string, want ...string) {
+		t.Helper()
+		match, err := versionFilter(expr)
+		assert.NoErr[F](t, err)
+		var got []string
+		for _, v := range versions {
+			if match(v) {
+				got = append(got, v)
+			}
+		}
+		assert.Equal[E](t, got, want)
+	}
+
+	test("1.20", "1.20.1", "1.20")
+	test(">=1.20", "1.21", "1.20.1", "1.20")
+	test(">1.20", "1.21", "1.20.1")
+	test("<1.20", "1.19.5", "1.18")
+	test("<=1.19.5", "1.19.5", "1.18")
+	test("=1.18", "1.18")
+
+	_, err := versionFilter(">=go1.20")
+	assert.Equal[F](t, err.Error(), `malformed version "go1.20" in ">=go1.20"`)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errNoPrevious is returned by `use -` if no version has been switched from yet.
+var errNoPrevious = errors.New("no previous version has been recorded yet")
+
+func previousPath() string { return filepath.Join(configDir, "previous") }
+
+// loadPrevious returns the version that was in use before the last switch.
+func loadPrevious() (string, error) {
+	data, err := os.ReadFile(previousPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", errNoPrevious
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// savePrevious records the version that was in use before the switch.
+func savePrevious(version string) error {
+	if version == "" || version == unknownVersion {
+		return nil // nothing to record (e.g. the go symlink was dangling).
+	}
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(previousPath(), []byte(version+"\n"), 0o644)
+}